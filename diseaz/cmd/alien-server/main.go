@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/tarstars/icfpc2020/diseaz/emulator"
+)
+
+func main() {
+	addr := flag.String("addr", ":12345", "Listen address")
+	maxTicks := flag.Int64("max-ticks", 256, "Game length in ticks")
+	timeout := flag.Duration("timeout", 5*time.Second, "How long to wait for the other player")
+	flag.Parse()
+
+	s := emulator.NewServer(*maxTicks, *timeout)
+	mux := http.NewServeMux()
+	mux.Handle("/aliens/send", s)
+	log.Printf("Alien server listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
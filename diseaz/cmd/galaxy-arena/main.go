@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tarstars/icfpc2020/diseaz/emulator"
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/replay"
+	"github.com/tarstars/icfpc2020/diseaz/strategy"
+)
+
+// Match is one game between two strategies.
+type Match struct {
+	Index    int
+	Attacker string
+	Defender string
+	// Seed chooses the start positions.
+	Seed   int64
+	Winner proto.Role
+	Ticks  int64
+	Error  string `json:",omitempty"`
+}
+
+// WinnerName returns the strategy that won the match.
+func (m *Match) WinnerName() string {
+	if m.Winner == proto.RoleAttacker {
+		return m.Attacker
+	}
+	return m.Defender
+}
+
+// LoserName returns the strategy that lost the match.
+func (m *Match) LoserName() string {
+	if m.Winner == proto.RoleAttacker {
+		return m.Defender
+	}
+	return m.Attacker
+}
+
+// play runs the match on s from start positions moved by up to jitter and
+// fills in the outcome. The game is recorded from the attacker side into rep
+// unless it is nil.
+func play(s *emulator.Server, m *Match, jitter int64, rep *replay.Replay) {
+	attacker, err := strategy.New(m.Attacker)
+	if err != nil {
+		m.Error = err.Error()
+		return
+	}
+	defender, err := strategy.New(m.Defender)
+	if err != nil {
+		m.Error = err.Error()
+		return
+	}
+	var onResponse emulator.ResponseFunc
+	if rep != nil {
+		onResponse = func(role proto.Role, name string, resp gx.Token, gr *proto.GameResponse) {
+			if role == proto.RoleAttacker {
+				rep.Add(gr)
+			}
+		}
+	}
+
+	starts := emulator.RandomStarts(rand.New(rand.NewSource(m.Seed)), jitter)
+	m.Winner, m.Ticks, err = s.PlayMatchFrom(starts, attacker, defender, onResponse)
+	if err != nil {
+		m.Error = err.Error()
+	}
+	if rep != nil && err == nil {
+		rep.Outcome = &replay.Outcome{Winner: m.Winner, Ticks: m.Ticks}
+	}
+}
+
+// schedule returns games matches for every pair of different attacker and
+// defender. Every pair plays the same games seeded from seed on.
+func schedule(names []string, games int, seed int64) []*Match {
+	var ms []*Match
+	for _, a := range names {
+		for _, d := range names {
+			if a == d {
+				continue
+			}
+			for i := 0; i < games; i++ {
+				ms = append(ms, &Match{Index: len(ms), Attacker: a, Defender: d, Seed: seed + int64(i)})
+			}
+		}
+	}
+	return ms
+}
+
+func main() {
+	strategies := flag.String("strategies", "", "Comma separated strategies to play, all registered if empty")
+	games := flag.Int("games", 4, "Games per attacker and defender pair")
+	parallel := flag.Int("parallel", runtime.NumCPU(), "Matches to run concurrently, at least 1")
+	maxTicks := flag.Int64("max-ticks", 256, "Game length in ticks")
+	timeout := flag.Duration("timeout", 10*time.Second, "How long the emulator waits for a player")
+	jitter := flag.Int64("jitter", 4, "Move the start positions of every game by up to this along each axis")
+	seed := flag.Int64("seed", 1, "Seed of the start positions of the first game of every pair")
+	jsonOut := flag.String("json", "", "Write the report as JSON to file, - for stdout")
+	replays := flag.String("replays", "", "Save replays of all matches to directory")
+	verbose := flag.Bool("v", false, "Log server traffic")
+	flag.Parse()
+	if *parallel < 1 {
+		log.Fatalf("-parallel must be at least 1, got %d", *parallel)
+	}
+
+	names := strategy.Names()
+	if len(*strategies) > 0 {
+		names = strings.Split(*strategies, ",")
+		for _, n := range names {
+			if _, err := strategy.New(n); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if !*verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+
+	if len(*replays) > 0 {
+		if err := os.MkdirAll(*replays, 0777); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	s := emulator.NewServer(*maxTicks, *timeout)
+	s.Quiet = !*verbose
+
+	matches := schedule(names, *games, *seed)
+	if len(matches) == 0 {
+		log.SetOutput(os.Stderr)
+		log.Fatal("Nothing to play: strategies don't play against themselves, give at least two")
+	}
+	queue := make(chan *Match)
+	var wg sync.WaitGroup
+	for i := 0; i < *parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range queue {
+				if len(*replays) == 0 {
+					play(s, m, *jitter, nil)
+					continue
+				}
+				rep := &replay.Replay{}
+				play(s, m, *jitter, rep)
+				fn := filepath.Join(*replays, fmt.Sprintf("%04d-%s-%s.json", m.Index, m.Attacker, m.Defender))
+				if err := rep.Save(fn); err != nil {
+					m.Error = err.Error()
+				}
+			}
+		}()
+	}
+	for _, m := range matches {
+		queue <- m
+	}
+	close(queue)
+	wg.Wait()
+	log.SetOutput(os.Stderr)
+
+	r := NewReport(names, *games, matches)
+	if *jsonOut != "-" {
+		r.WriteTable(os.Stdout)
+	}
+	if len(*jsonOut) > 0 {
+		w := os.Stdout
+		if *jsonOut != "-" {
+			f, err := os.Create(*jsonOut)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			w = f
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
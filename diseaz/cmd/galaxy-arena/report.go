@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+)
+
+const (
+	initialElo = 1500
+	eloK       = 32
+)
+
+// Standing is the overall result of one strategy.
+type Standing struct {
+	Name    string
+	Elo     float64
+	Wins    int
+	Losses  int
+	WinRate float64
+}
+
+// Report summarizes a tournament.
+type Report struct {
+	Strategies []string
+	Games      int
+	// AttackerWinRate[a][d] is the share of games attacker a won against
+	// defender d. Strategies don't play against themselves.
+	AttackerWinRate map[string]map[string]float64
+	Standings       []*Standing
+	Errors          int
+	Matches         []*Match
+}
+
+// expectedScore is the Elo win probability of rating a against rating b.
+func expectedScore(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// NewReport rates the finished matches in their scheduled order. Matches
+// that failed are not rated.
+func NewReport(names []string, games int, matches []*Match) *Report {
+	r := &Report{
+		Strategies:      names,
+		Games:           games,
+		AttackerWinRate: make(map[string]map[string]float64),
+		Matches:         matches,
+	}
+	byName := make(map[string]*Standing)
+	for _, n := range names {
+		byName[n] = &Standing{Name: n, Elo: initialElo}
+		r.Standings = append(r.Standings, byName[n])
+		r.AttackerWinRate[n] = make(map[string]float64)
+	}
+
+	played := make(map[string]map[string]int)
+	for _, n := range names {
+		played[n] = make(map[string]int)
+	}
+	for _, m := range matches {
+		if m.Error != "" {
+			r.Errors++
+			continue
+		}
+		played[m.Attacker][m.Defender]++
+		if m.Winner == proto.RoleAttacker {
+			r.AttackerWinRate[m.Attacker][m.Defender]++
+		}
+
+		w, l := byName[m.WinnerName()], byName[m.LoserName()]
+		w.Wins++
+		l.Losses++
+		delta := eloK * (1 - expectedScore(w.Elo, l.Elo))
+		w.Elo += delta
+		l.Elo -= delta
+	}
+
+	for a, ds := range played {
+		for d, n := range ds {
+			r.AttackerWinRate[a][d] /= float64(n)
+		}
+	}
+	for _, s := range r.Standings {
+		if n := s.Wins + s.Losses; n > 0 {
+			s.WinRate = float64(s.Wins) / float64(n)
+		}
+	}
+	sort.SliceStable(r.Standings, func(i, j int) bool {
+		return r.Standings[i].Elo > r.Standings[j].Elo
+	})
+	return r
+}
+
+// WriteTable writes the win-rate matrix and the ranking as text tables.
+func (r *Report) WriteTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "attacker \\ defender\t")
+	for _, d := range r.Strategies {
+		fmt.Fprintf(tw, "%s\t", d)
+	}
+	fmt.Fprintln(tw)
+	for _, a := range r.Strategies {
+		fmt.Fprintf(tw, "%s\t", a)
+		for _, d := range r.Strategies {
+			if a == d {
+				fmt.Fprint(tw, "-\t")
+				continue
+			}
+			fmt.Fprintf(tw, "%.2f\t", r.AttackerWinRate[a][d])
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "#\tstrategy\telo\twins\tlosses\twin rate\t")
+	for i, s := range r.Standings {
+		fmt.Fprintf(tw, "%d\t%s\t%.0f\t%d\t%d\t%.2f\t\n", i+1, s.Name, s.Elo, s.Wins, s.Losses, s.WinRate)
+	}
+	tw.Flush()
+	if r.Errors > 0 {
+		fmt.Fprintf(w, "\n%d matches failed\n", r.Errors)
+	}
+}
@@ -8,13 +8,15 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/replay"
+	"github.com/tarstars/icfpc2020/diseaz/strategy"
 )
 
-type ICons = gx.ICons
-type Int = gx.Int
-
 type Result struct {
 	Picture *gx.Picture `json:",inline"`
 	Results []string    `json:""`
@@ -26,258 +28,57 @@ func (r *Result) AddResults(anss ...gx.Token) {
 	}
 }
 
-func command(c gx.Context, name string, program string) *GameResponse {
-	rs := gx.ParseString(c, program)
-	if len(rs) == 0 {
-		return nil
-	}
-
+// logResponse writes the raw response to stdout and the decoded one to the
+// log.
+func logResponse(c *gx.Ctx, name string, resp gx.Token, gr *proto.GameResponse) {
 	logr := &Result{}
-	logr.AddResults(rs...)
+	logr.AddResults(resp)
 	logr.Picture = c.Picture()
 	json.NewEncoder(os.Stdout).Encode(logr)
 
-	gr := ParseGameResponse(rs[0].(ICons))
+	if gr == nil {
+		return
+	}
 	grJSON, err := json.Marshal(gr)
 	if err != nil {
-		log.Panicf("GameResponse marshaling to JSON failed: %s", err)
-	}
-	log.Printf("GameResponse: %s", string(grJSON))
-	if gr.Error {
-		log.Panic(fmt.Errorf("%s failed", name))
+		log.Fatalf("GameResponse marshaling to JSON failed: %s", err)
 	}
-
-	return &gr
+	log.Printf("%s GameResponse: %s", name, string(grJSON))
 }
 
-func checkListEnd(name string, endItem gx.Token) {
-	endItemC, isCons := endItem.(ICons)
-	if !isCons || !endItemC.IsNil() {
-		log.Printf("Unexpected extra data for %s: %s", name, endItem.Galaxy())
+// checkReplay fails if the requests differed from the replayed recording.
+func checkReplay(rt *gx.ReplayTransport) {
+	if rt == nil {
+		return
 	}
-}
-
-type GameResponse struct {
-	Error      bool
-	Stage      GameStage
-	StaticInfo *GameStaticInfo
-	State      *GameState
-}
-
-func ParseGameResponse(itemX0 ICons) (gr GameResponse) {
-	status := itemX0.Car().(Int).V
-	if status == 0 {
-		return GameResponse{Error: true}
+	if ds := rt.Diverged(); len(ds) > 0 {
+		log.Fatalf("Replay diverged %d times, first: %s", len(ds), ds[0])
 	}
-
-	itemX1 := itemX0.Cdr().(ICons)
-	gr.Stage = GameStage(itemX1.Car().(Int).V)
-	itemX2 := itemX1.Cdr().(ICons)
-	gr.StaticInfo = ParseGameStaticInfo(itemX2.Car().(ICons))
-	itemX3 := itemX2.Cdr().(ICons)
-	gr.State = ParseGameState(itemX3.Car().(ICons))
-
-	checkListEnd("GameResponse", itemX3.Cdr())
-
-	return gr
-}
-
-type GameState struct {
-	Tick  int64
-	X1    string
-	Ships []*ShipAndCommands
-}
-
-func ParseGameState(itemX0 ICons) (gs *GameState) {
-	if itemX0.IsNil() {
-		return nil
-	}
-
-	gs = &GameState{}
-
-	gs.Tick = itemX0.Car().(Int).V
-	itemX1 := itemX0.Cdr().(ICons)
-	gs.X1 = itemX1.Car().String()
-	itemX2 := itemX1.Cdr().(ICons)
-	gs.Ships = ParseShipsAndCommands(itemX2.Car().(ICons))
-
-	checkListEnd("ParseGameState", itemX2.Cdr())
-
-	return gs
 }
 
-func listNext(item ICons) (gx.Token, ICons) {
-	return item.Car(), item.Cdr().(ICons)
-}
-
-func ParseShipsAndCommands(itemX0 ICons) []*ShipAndCommands {
-	var ships []*ShipAndCommands
-	for item := itemX0; !item.IsNil(); item = item.Cdr().(ICons) {
-		ships = append(ships, ParseShip(item.Car().(ICons)))
-	}
-	return ships
-}
-
-type ShipAndCommands struct {
-	Ship     ShipState
-	Commands []string
-}
-
-func ParseShip(itemX0 ICons) (ship *ShipAndCommands) {
-	if itemX0.IsNil() {
-		return nil
+func main() {
+	responses := flag.String("responses", "", "Answer send requests from recorded responses file instead of the server")
+	replayFile := flag.String("replay", "", "Replay server traffic recorded with -record")
+	strict := flag.Bool("strict", false, "Fail at the first request that differs from the -replay recording")
+	record := flag.String("record", "", "Record server traffic to JSONL file")
+	saveReplay := flag.String("save-replay", "", "Save the game to replay file for galaxy-replay")
+	strategyName := flag.String("strategy", "idle",
+		fmt.Sprintf("Strategy to play, one of %s", strings.Join(strategy.Names(), ", ")))
+	paramsFile := flag.String("params", "", "Read START params from galaxy-optimize config file")
+	timeout := flag.Duration("timeout", 10*time.Second, "Abandon a server request not answered in this time, COMMANDS are retried; 0 to wait forever")
+	flag.Parse()
+	if len(*responses) > 0 && len(*replayFile) > 0 {
+		log.Fatal("-responses and -replay can't be used together")
 	}
 
-	ship.Ship = ParseShipState(itemX0.Car().(ICons))
-	itemX1 := itemX0.Cdr().(ICons)
-	ship.Commands = ParseCommands(itemX1.Car().(ICons))
-
-	checkListEnd("ParseShip", itemX1.Cdr())
-
-	return ship
-}
-
-func ParseCommands(itemX0 ICons) (cmds []string) {
-	for item := itemX0; !item.IsNil(); item = item.Cdr().(ICons) {
-		cmds = append(cmds, item.Car().String())
+	st, err := strategy.New(*strategyName)
+	if err != nil {
+		log.Panic(err)
 	}
-	return cmds
-}
-
-type ShipState struct {
-	Role     Role
-	ID       int64
-	Position gx.Point
-	Velocity gx.Point
-	Extra    []string
-}
-
-func ParseShipState(itemX0 ICons) (ss ShipState) {
-
-	ss.Role = Role(itemX0.Car().(Int).V)
-	itemX1 := itemX0.Cdr().(ICons)
-	ss.ID = itemX1.Car().(Int).V
-	itemX2 := itemX1.Cdr().(ICons)
-	ss.Position = ParsePoint(itemX2.Car().(ICons))
-	itemX3 := itemX2.Cdr().(ICons)
-	ss.Velocity = ParsePoint(itemX3.Car().(ICons))
-	itemX4 := itemX3.Cdr().(ICons)
-	for item := itemX4; !item.IsNil(); item = item.Cdr().(ICons) {
-		ss.Extra = append(ss.Extra, item.Car().String())
+	if len(*paramsFile) > 0 {
+		st = strategy.WithParamsFile(st, *paramsFile)
 	}
 
-	return ss
-}
-
-func ParsePoint(itemX0 ICons) (p gx.Point) {
-
-	p.X = int(itemX0.Car().(Int).V)
-	p.Y = int(itemX0.Cdr().(Int).V)
-
-	return p
-}
-
-type GameStaticInfo struct {
-	X0   int64
-	Role Role
-	X2   string
-	X3   string
-	X4   string
-}
-
-func ParseGameStaticInfo(infoX0Item ICons) *GameStaticInfo {
-	if infoX0Item.IsNil() {
-		return nil
-	}
-	gi := GameStaticInfo{}
-
-	gi.X0 = infoX0Item.Car().(Int).V
-	infoX1Item := infoX0Item.Cdr().(ICons)
-	gi.Role = Role(infoX1Item.Car().(Int).V)
-	infoX2Item := infoX1Item.Cdr().(ICons)
-	gi.X2 = infoX2Item.Car().String()
-	infoX3Item := infoX2Item.Cdr().(ICons)
-	gi.X3 = infoX3Item.Car().String()
-	infoX4Item := infoX3Item.Cdr().(ICons)
-	gi.X4 = infoX4Item.Car().String()
-
-	checkListEnd("GameStaticInfo", infoX4Item.Cdr())
-
-	return &gi
-}
-
-type GameStaticInfoX2 struct {
-	X0 int64
-	X1 int64
-	X2 int64
-}
-
-func ParseGameStaticInfoX2(itemX0 ICons) (info GameStaticInfoX2) {
-	info.X0 = itemX0.Car().(Int).V
-	itemX1 := itemX0.Cdr().(ICons)
-	info.X1 = itemX1.Car().(Int).V
-	itemX2 := itemX1.Cdr().(ICons)
-	info.X2 = itemX2.Car().(Int).V
-
-	checkListEnd("ParseGameStaticInfoX2", itemX2.Cdr())
-
-	return info
-}
-
-type GameStaticInfoX3 struct {
-	X0 int64
-	X1 int64
-}
-
-func ParseGameStaticInfoX3(itemX0 ICons) (info GameStaticInfoX3) {
-	info.X0 = itemX0.Car().(Int).V
-	itemX1 := itemX0.Cdr().(ICons)
-	info.X1 = itemX1.Car().(Int).V
-
-	checkListEnd("ParseGameStaticInfoX3", itemX1.Cdr())
-
-	return info
-}
-
-type GameStaticInfoX4 struct {
-	X0 int64
-	X1 int64
-	X2 int64
-	X3 int64
-}
-
-func ParseGameStaticInfoX4(itemX0 ICons) (info GameStaticInfoX4) {
-	info.X0 = itemX0.Car().(Int).V
-	itemX1 := itemX0.Cdr().(ICons)
-	info.X1 = itemX1.Car().(Int).V
-	itemX2 := itemX1.Cdr().(ICons)
-	info.X2 = itemX2.Car().(Int).V
-	itemX3 := itemX2.Cdr().(ICons)
-	info.X3 = itemX3.Car().(Int).V
-
-	checkListEnd("ParseGameStaticInfoX4", itemX3.Cdr())
-
-	return info
-}
-
-type Role int
-
-const (
-	RoleAttacker Role = 0
-	RoleDefender Role = 1
-)
-
-type GameStage int
-
-const (
-	GamePending  GameStage = 0
-	GameStarted  GameStage = 1
-	GameFinished GameStage = 2
-)
-
-func main() {
-	flag.Parse()
-
 	serverURL, err := url.Parse(flag.Arg(0))
 	if err != nil {
 		log.Panic(err)
@@ -289,28 +90,52 @@ func main() {
 	}
 	log.Printf("ServerUrl: %s; PlayerKey: %d", serverURL, playerKey)
 
-	c := gx.NewContext(serverURL)
-	gs := command(c, "JOIN", fmt.Sprintf("ap send (2, %d, nil)", playerKey))
-	if gs.Stage == GameFinished {
-		return
+	var opts []gx.Option
+	if len(*responses) > 0 {
+		ft, err := gx.OpenFileTransport(*responses)
+		if err != nil {
+			log.Panic(err)
+		}
+		opts = append(opts, gx.WithTransport(ft))
 	}
-
-	gs = command(c, "START", fmt.Sprintf("ap send (3, %d, (1, 1, 1, 1))", playerKey))
-	if gs.Stage == GameFinished {
-		return
+	var rt *gx.ReplayTransport
+	if len(*replayFile) > 0 {
+		rt, err = gx.OpenReplayTransport(*replayFile)
+		if err != nil {
+			log.Panic(err)
+		}
+		rt.Strict = *strict
+		opts = append(opts, gx.WithTransport(rt))
 	}
-
-	var shipId int64
-
-	for _, ship := range gs.State.Ships {
-		if ship.Ship.Role == gs.StaticInfo.Role {
-			shipId = ship.Ship.ID
+	if len(*record) > 0 {
+		f, err := os.Create(*record)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer f.Close()
+		opts = append(opts, gx.WithRecording(f))
+	}
+
+	c := gx.NewContext(serverURL, opts...)
+	defer checkReplay(rt)
+	rep := &replay.Replay{}
+	p := &strategy.Player{
+		Ctx:       c,
+		PlayerKey: playerKey,
+		Strategy:  st,
+		Timeout:   *timeout,
+		OnResponse: func(name string, resp gx.Token, gr *proto.GameResponse) {
+			logResponse(c, name, resp, gr)
+			rep.Add(gr)
+		},
+	}
+	_, err = p.Play()
+	if len(*saveReplay) > 0 {
+		if err := rep.Save(*saveReplay); err != nil {
+			log.Printf("Saving replay failed: %s", err)
 		}
 	}
-
-	log.Printf("Ship ID: %d", shipId)
-
-	for gs.Stage != GameFinished {
-		gs = command(c, "NOP", fmt.Sprintf("ap send ap ap cons 0 ap ap cons %d ap ap cons ap ap cons 0 0 nil", shipId))
+	if err != nil {
+		log.Panic(err)
 	}
 }
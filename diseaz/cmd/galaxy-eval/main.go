@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"image"
 	"image/png"
+	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
@@ -12,17 +16,28 @@ import (
 	"github.com/tarstars/icfpc2020/diseaz/interpreter"
 )
 
-func savePicture(fn string, pic image.Image) {
+func savePicture(fn string, pic image.Image) error {
 	f, err := os.Create(fn)
 	if err != nil {
-		log.Panic(err)
+		return err
+	}
+	if err := png.Encode(f, pic); err != nil {
+		f.Close()
+		return err
 	}
-	defer f.Close()
+	return f.Close()
+}
 
-	err = png.Encode(f, pic)
+func writeProfile(fn string, p *interpreter.Profiler) error {
+	f, err := os.Create(fn)
 	if err != nil {
-		log.Panic(err)
+		return err
+	}
+	if err := p.WritePprof(f); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
 }
 
 type Result struct {
@@ -30,11 +45,42 @@ type Result struct {
 	Results []string             `json:""`
 }
 
+// checkReplay fails if the requests differed from the replayed recording.
+func checkReplay(rt *interpreter.ReplayTransport) {
+	if rt == nil {
+		return
+	}
+	if ds := rt.Diverged(); len(ds) > 0 {
+		log.Fatalf("Replay diverged %d times, first: %s", len(ds), ds[0])
+	}
+}
+
 func main() {
 	server := flag.String("server", "https://icfpc2020-api.testkontur.ru/aliens/send", "Server URL")
 	key := flag.String("key", "faa0647bb89f42d6a0a1850cf1b71954", "Player key")
 	drawOut := flag.String("draw", "", "Output picture file")
+	lazy := flag.Bool("lazy", false, "Call-by-need evaluation with shared thunks")
+	graph := flag.Bool("graph", false, "Evaluate with the graph reduction evaluator (implies -lazy)")
+	serveAddr := flag.String("serve", "", "Serve interactive galaxy viewer on address (e.g. :8080)")
+	responses := flag.String("responses", "", "Answer send requests from recorded responses file instead of the server")
+	replay := flag.String("replay", "", "Replay server traffic recorded with -record")
+	strict := flag.Bool("strict", false, "Fail at the first request that differs from the -replay recording")
+	record := flag.String("record", "", "Record server traffic to JSONL file")
+	replMode := flag.Bool("repl", false, "Read definitions and expressions interactively after evaluating the files")
+	history := flag.String("history", defaultHistoryFile(), "REPL history file, none if empty")
+	verbose := flag.Bool("v", false, "Keep logging evaluation and server traffic in -repl mode")
+	timeout := flag.Duration("timeout", 0, "Stop evaluating the files, or a REPL line, after this time; 0 for no limit")
+	maxSteps := flag.Int("max-steps", 0, "Reduction steps allowed for one expression; 0 for no limit")
+	maxDepth := flag.Int("max-depth", 0, "Nesting of evaluation allowed for one expression; 0 for no limit")
+	traceFile := flag.String("trace", "", "Write evaluation trace to JSONL file")
+	traceWidth := flag.Int("trace-width", 200, "Cut expressions in -trace output to this length; 0 for full expressions")
+	stepMode := flag.Bool("step", false, "Evaluate the files step by step, reading step commands from stdin")
+	profileFile := flag.String("profile", "", "Write pprof profile of evaluating the files by definition and combinator")
+	profileTop := flag.Int("profile-top", 0, "Print this many top definitions and combinators of evaluating the files to stderr")
 	flag.Parse()
+	if len(*responses) > 0 && len(*replay) > 0 {
+		log.Fatal("-responses and -replay can't be used together")
+	}
 
 	serverURL, err := url.Parse(*server)
 	if err != nil {
@@ -45,7 +91,68 @@ func main() {
 	serverURL.RawQuery = values.Encode()
 	log.Printf("ServerUrl: %s", serverURL)
 
-	c := interpreter.NewContext(serverURL)
+	var opts []interpreter.Option
+	if len(*responses) > 0 {
+		ft, err := interpreter.OpenFileTransport(*responses)
+		if err != nil {
+			log.Panic(err)
+		}
+		opts = append(opts, interpreter.WithTransport(ft))
+	}
+	var rt *interpreter.ReplayTransport
+	if len(*replay) > 0 {
+		rt, err = interpreter.OpenReplayTransport(*replay)
+		if err != nil {
+			log.Panic(err)
+		}
+		rt.Strict = *strict
+		opts = append(opts, interpreter.WithTransport(rt))
+	}
+	if len(*record) > 0 {
+		f, err := os.Create(*record)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer f.Close()
+		opts = append(opts, interpreter.WithRecording(f))
+	}
+
+	c := interpreter.NewContext(serverURL, opts...)
+	c.Lazy = *lazy || *graph
+	c.Graph = *graph
+	c.MaxSteps = *maxSteps
+	c.MaxDepth = *maxDepth
+	if len(*traceFile) > 0 {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer f.Close()
+		c.Tracer = interpreter.NewJSONTracer(f, *traceWidth)
+	}
+	if *timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		c.Context = ctx
+	}
+
+	tracer := c.Tracer
+	var profiler *interpreter.Profiler
+	if len(*profileFile) > 0 || *profileTop > 0 {
+		profiler = interpreter.NewProfiler()
+		c.Tracer = interpreter.Tracers(c.Tracer, profiler)
+	}
+	if *stepMode {
+		in := bufio.NewReader(os.Stdin)
+		s := &stepper{
+			read: func(prompt string) (string, error) {
+				fmt.Fprint(os.Stderr, prompt)
+				return in.ReadString('\n')
+			},
+			out: os.Stderr,
+		}
+		c.Tracer = interpreter.Tracers(c.Tracer, interpreter.NewDebugger(s.prompt))
+	}
 
 	r := Result{}
 	for _, fn := range flag.Args() {
@@ -55,7 +162,10 @@ func main() {
 		}
 		defer f.Close()
 
-		toks := interpreter.ParseReader(c, f)
+		toks, err := interpreter.TryParseReader(c, f)
+		if err != nil {
+			log.Fatalf("%s: %s", fn, err)
+		}
 		for _, tok := range toks {
 			r.Results = append(r.Results, tok.Galaxy())
 			log.Printf("Result(s): %s", tok)
@@ -63,11 +173,42 @@ func main() {
 		}
 	}
 	log.Printf("Evals: %d", c.EvalCount)
+	checkReplay(rt)
+	if profiler != nil {
+		if *profileTop > 0 {
+			profiler.WriteTop(os.Stderr, *profileTop)
+		}
+		if len(*profileFile) > 0 {
+			if err := writeProfile(*profileFile, profiler); err != nil {
+				log.Panic(err)
+			}
+		}
+	}
+	c.Context = nil
+	c.Tracer = tracer
+
+	if *replMode {
+		if !*verbose {
+			log.SetOutput(ioutil.Discard)
+		}
+		if err := runREPL(c, *history, *timeout); err != nil {
+			log.SetOutput(os.Stderr)
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(*serveAddr) > 0 {
+		serve(*serveAddr, interpreter.NewContextSession(c))
+		return
+	}
 
 	r.Picture = c.Picture()
 	json.NewEncoder(os.Stdout).Encode(r)
 
 	if len(*drawOut) > 0 {
-		savePicture(*drawOut, c.Picture())
+		if err := savePicture(*drawOut, c.Picture()); err != nil {
+			log.Panic(err)
+		}
 	}
 }
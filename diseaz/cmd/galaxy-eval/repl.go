@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+
+	"github.com/tarstars/icfpc2020/diseaz/interpreter"
+)
+
+const replHelp = `Enter definitions ":N = expr" or "name = expr" and expressions in galaxy.txt syntax.
+Commands:
+  :show var          show the definition of :N or name
+  :decompile var     show the definition as lambda pseudo-code
+  :step expr         evaluate expr step by step
+  :vars              list the defined variables
+  :modulate expr     evaluate expr and modulate the result
+  :demodulate bits   demodulate a signal
+  :draw file.png     save the picture drawn so far
+  :clear             clear the picture
+  :help              show this help
+  :quit              exit, as does Ctrl-D`
+
+// replCommand handles ":name args" input.
+type replCommand func(r *repl, args string) error
+
+var replCommands = map[string]replCommand{
+	"show":       (*repl).show,
+	"decompile":  (*repl).decompile,
+	"step":       (*repl).step,
+	"vars":       (*repl).vars,
+	"modulate":   (*repl).modulate,
+	"demodulate": (*repl).demodulate,
+	"draw":       (*repl).draw,
+	"clear":      (*repl).clear,
+	"help":       (*repl).help,
+}
+
+var errQuit = errors.New("quit")
+
+// repl evaluates galaxy lines typed by the user.
+type repl struct {
+	c    *interpreter.Ctx
+	out  io.Writer
+	read func(prompt string) (string, error)
+	// timeout limits the evaluation of a line if positive.
+	timeout time.Duration
+}
+
+// interruptible runs f with the context of r.c cancelled by Ctrl-C or after
+// the timeout.
+func (r *repl) interruptible(f func() error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if r.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, r.timeout)
+		defer cancelTimeout()
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	r.c.Context = ctx
+	defer func() { r.c.Context = nil }()
+	return f()
+}
+
+// handle evaluates one input line.
+func (r *repl) handle(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || interpreter.IsComment(fields[0]) {
+		return nil
+	}
+	if name := strings.TrimPrefix(fields[0], ":"); name != fields[0] {
+		if name == "quit" {
+			return errQuit
+		}
+		if cmd, ok := replCommands[name]; ok {
+			return cmd(r, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0])))
+		}
+	}
+	return r.eval(line)
+}
+
+// eval evaluates a definition or an expression.
+func (r *repl) eval(line string) error {
+	toks, err := interpreter.TryParseLine(r.c, line)
+	if err != nil {
+		return err
+	}
+	if len(toks) == 0 {
+		fmt.Fprintf(r.out, "Defined %s\n", strings.Fields(line)[0])
+	}
+	for _, tok := range toks {
+		r.print(tok)
+	}
+	return nil
+}
+
+func (r *repl) print(tok interpreter.Token) {
+	fmt.Fprintf(r.out, "%s\n%s\n", tok, tok.Galaxy())
+}
+
+// parseVar parses a variable ":N" or a name known to the context.
+func (r *repl) parseVar(s string) (interpreter.VarN, error) {
+	if interpreter.IsName(s) {
+		if v, ok := r.c.Symbols().Lookup(s); ok {
+			return v, nil
+		}
+		return interpreter.VarN{}, &interpreter.UnboundVariable{Name: s}
+	}
+	if !strings.HasPrefix(s, ":") {
+		return interpreter.VarN{}, fmt.Errorf("Bad variable %q", s)
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil {
+		return interpreter.VarN{}, fmt.Errorf("Bad variable %q", s)
+	}
+	return interpreter.VarN{N: n}, nil
+}
+
+func (r *repl) show(args string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: :show var")
+	}
+	v, err := r.parseVar(args)
+	if err != nil {
+		return err
+	}
+	tok, ok := r.c.Definition(v.N)
+	if !ok {
+		return &interpreter.UnboundVariable{N: v.N, Name: v.Name}
+	}
+	r.print(tok)
+	return nil
+}
+
+func (r *repl) decompile(args string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: :decompile var")
+	}
+	v, err := r.parseVar(args)
+	if err != nil {
+		return err
+	}
+	term, err := r.c.Decompile(v.N)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "%s = %s\n", v.Galaxy(), term)
+	return nil
+}
+
+func (r *repl) step(args string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: :step expr")
+	}
+	s := &stepper{read: r.read, out: r.out}
+	fmt.Fprintln(r.out, "Stepping, h for help")
+	tracer := r.c.Tracer
+	r.c.Tracer = interpreter.Tracers(tracer, interpreter.NewDebugger(s.prompt))
+	defer func() { r.c.Tracer = tracer }()
+	return r.eval(args)
+}
+
+func (r *repl) vars(args string) error {
+	ns := r.c.Defined()
+	for _, n := range ns {
+		fmt.Fprintf(r.out, "%s ", r.c.Symbols().Var(n).Galaxy())
+	}
+	fmt.Fprintf(r.out, "\n%d variables\n", len(ns))
+	return nil
+}
+
+func (r *repl) modulate(args string) error {
+	toks, err := interpreter.TryParseLine(r.c, "ap mod "+args)
+	if err != nil {
+		return err
+	}
+	for _, tok := range toks {
+		if s, ok := tok.(interpreter.Signal); ok {
+			fmt.Fprintln(r.out, s.S)
+		}
+	}
+	return nil
+}
+
+func (r *repl) demodulate(args string) error {
+	tok, err := interpreter.TryDemodulateToken(args)
+	if err != nil {
+		return err
+	}
+	r.print(tok)
+	return nil
+}
+
+func (r *repl) draw(args string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: :draw file.png")
+	}
+	if r.c.Picture().Bounds().Empty() {
+		return fmt.Errorf("Nothing drawn yet")
+	}
+	if err := savePicture(args, r.c.Picture()); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.out, "Saved %s\n", args)
+	return nil
+}
+
+func (r *repl) clear(args string) error {
+	r.c.Pic = interpreter.NewPicture()
+	return nil
+}
+
+func (r *repl) help(args string) error {
+	fmt.Fprintln(r.out, replHelp)
+	return nil
+}
+
+// complete completes command names and defined variables.
+func (r *repl) complete(line string) []string {
+	i := strings.LastIndexAny(line, " (,") + 1
+	prefix, word := line[:i], line[i:]
+	if len(word) == 0 {
+		return nil
+	}
+	var cs []string
+	if i == 0 {
+		for name := range replCommands {
+			cs = append(cs, ":"+name)
+		}
+		cs = append(cs, ":quit")
+	}
+	for _, n := range r.c.Defined() {
+		cs = append(cs, r.c.Symbols().Var(n).Galaxy())
+	}
+	var rs []string
+	for _, c := range cs {
+		if strings.HasPrefix(c, word) {
+			rs = append(rs, prefix+c)
+		}
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".galaxy_eval_history")
+}
+
+// runREPL reads lines with editing and history until EOF or :quit. History
+// is kept in historyFile unless it is empty. Ctrl-C interrupts the evaluation
+// of a line.
+func runREPL(c *interpreter.Ctx, historyFile string, timeout time.Duration) error {
+	ln := liner.NewLiner()
+	defer ln.Close()
+	r := &repl{c: c, out: os.Stdout, read: ln.Prompt, timeout: timeout}
+
+	ln.SetCtrlCAborts(true)
+	ln.SetCompleter(r.complete)
+	if len(historyFile) > 0 {
+		if f, err := os.Open(historyFile); err == nil {
+			ln.ReadHistory(f)
+			f.Close()
+		}
+	}
+
+	fmt.Fprintln(r.out, "Type :help for commands")
+	for {
+		line, err := ln.Prompt("galaxy> ")
+		if err == liner.ErrPromptAborted {
+			continue
+		}
+		if err == io.EOF {
+			fmt.Fprintln(r.out)
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		ln.AppendHistory(line)
+		if err := r.interruptible(func() error { return r.handle(line) }); err == errQuit {
+			break
+		} else if err != nil {
+			fmt.Fprintf(r.out, "Error: %s\n", err)
+		}
+	}
+
+	if len(historyFile) > 0 {
+		f, err := os.Create(historyFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := ln.WriteHistory(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
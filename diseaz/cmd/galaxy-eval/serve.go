@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image/color"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tarstars/icfpc2020/diseaz/interpreter"
+)
+
+// viewer serves a galaxy session to the browser.
+type viewer struct {
+	mu sync.Mutex
+	s  *interpreter.Session
+}
+
+type viewState struct {
+	State   string
+	History int
+	Future  int
+	Layers  [][]interpreter.Point
+	Error   string `json:",omitempty"`
+}
+
+func serve(addr string, s *interpreter.Session) {
+	v := &viewer{s: s}
+	if _, _, err := s.ClickCurrent(0, 0); err != nil {
+		log.Panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", v.handleIndex)
+	mux.HandleFunc("/state", v.handleState)
+	mux.HandleFunc("/picture.svg", v.handleSVG)
+	mux.HandleFunc("/layer.png", v.handlePNG)
+	mux.HandleFunc("/click", postOnly(v.handleClick))
+	mux.HandleFunc("/back", postOnly(v.handleBack))
+	mux.HandleFunc("/forward", postOnly(v.handleForward))
+	mux.HandleFunc("/reset", postOnly(v.handleReset))
+
+	log.Printf("Serving galaxy viewer on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+// postOnly rejects requests to h other than POST, the handlers change the
+// session.
+func postOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (v *viewer) state(err error) viewState {
+	r := viewState{
+		State:   v.s.State.Galaxy(),
+		History: len(v.s.History),
+		Future:  len(v.s.Future),
+	}
+	for _, pic := range v.s.Pics {
+		r.Layers = append(r.Layers, pic.Serial())
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+func (v *viewer) writeState(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(v.state(err))
+}
+
+func (v *viewer) handleState(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.writeState(w, nil)
+}
+
+func (v *viewer) handleClick(w http.ResponseWriter, r *http.Request) {
+	x, errX := strconv.Atoi(r.FormValue("x"))
+	y, errY := strconv.Atoi(r.FormValue("y"))
+	if errX != nil || errY != nil {
+		http.Error(w, "x and y must be integers", http.StatusBadRequest)
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	log.Printf("Click: %d, %d", x, y)
+	_, _, err := v.s.ClickCurrent(x, y)
+	v.writeState(w, err)
+}
+
+func (v *viewer) handleBack(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.s.Undo()
+	v.writeState(w, nil)
+}
+
+func (v *viewer) handleForward(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.s.Redo()
+	v.writeState(w, nil)
+}
+
+func (v *viewer) handleReset(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.s.Reset()
+	_, _, err := v.s.ClickCurrent(0, 0)
+	v.writeState(w, err)
+}
+
+// handleSVG draws every layer as a group of unit squares in galaxy
+// coordinates, so the viewBox maps clicks straight to galaxy points.
+func (v *viewer) handleSVG(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	minX, minY, maxX, maxY := -8, -8, 8, 8
+	for _, pic := range v.s.Pics {
+		for p := range pic.Pts {
+			if p.X < minX {
+				minX = p.X
+			}
+			if p.Y < minY {
+				minY = p.Y
+			}
+			if p.X+1 > maxX {
+				maxX = p.X + 1
+			}
+			if p.Y+1 > maxY {
+				maxY = p.Y + 1
+			}
+		}
+	}
+	minX, minY, maxX, maxY = minX-2, minY-2, maxX+2, maxY+2
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" id="galaxy" viewBox="%d %d %d %d" shape-rendering="crispEdges">`,
+		minX, minY, maxX-minX, maxY-minY)
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="black"/>`, minX, minY, maxX-minX, maxY-minY)
+	for i, pic := range v.s.Pics {
+		c := interpreter.Palette[i%len(interpreter.Palette)].(color.RGBA)
+		fmt.Fprintf(&b, `<g class="layer" fill="rgb(%d,%d,%d)" fill-opacity="%.2f">`, c.R, c.G, c.B, float64(c.A)/0xff)
+		for _, p := range pic.Serial() {
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="1" height="1"/>`, p.X, p.Y)
+		}
+		b.WriteString(`</g>`)
+	}
+	b.WriteString(`</svg>`)
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write([]byte(b.String()))
+}
+
+func (v *viewer) handlePNG(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	i, err := strconv.Atoi(r.FormValue("i"))
+	if err != nil || i < 0 || i >= len(v.s.Pics) {
+		http.Error(w, "no such layer", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, v.s.Pics[i])
+}
+
+func (v *viewer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	indexTemplate.Execute(w, nil)
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Galaxy</title>
+<style>
+body { background: #222; color: #ddd; font-family: monospace; }
+#view { width: 95vmin; height: 95vmin; cursor: crosshair; }
+#view svg { width: 100%; height: 100%; }
+#state { word-break: break-all; }
+</style>
+</head>
+<body>
+<div>
+<button onclick="post('/back')">Back</button>
+<button onclick="post('/forward')">Forward</button>
+<button onclick="post('/reset')">Reset</button>
+<span id="pos"></span>
+<span id="error" style="color: #f66"></span>
+</div>
+<div id="view"></div>
+<div id="state"></div>
+<script>
+function galaxyPoint(evt) {
+  var svg = document.getElementById('galaxy');
+  var pt = svg.createSVGPoint();
+  pt.x = evt.clientX;
+  pt.y = evt.clientY;
+  var p = pt.matrixTransform(svg.getScreenCTM().inverse());
+  return {x: Math.floor(p.x), y: Math.floor(p.y)};
+}
+
+function refresh(st) {
+  document.getElementById('state').textContent =
+    st.State + ' (back: ' + st.History + ', forward: ' + st.Future + ')';
+  document.getElementById('error').textContent = st.Error || '';
+  fetch('/picture.svg').then(r => r.text()).then(svg => {
+    var view = document.getElementById('view');
+    view.innerHTML = svg;
+    var g = document.getElementById('galaxy');
+    g.addEventListener('mousemove', e => {
+      var p = galaxyPoint(e);
+      document.getElementById('pos').textContent = p.x + ', ' + p.y;
+    });
+    g.addEventListener('click', e => {
+      var p = galaxyPoint(e);
+      post('/click?x=' + p.x + '&y=' + p.y);
+    });
+  });
+}
+
+function post(url) {
+  fetch(url, {method: 'POST'}).then(r => r.json()).then(refresh);
+}
+
+fetch('/state').then(r => r.json()).then(refresh);
+</script>
+</body>
+</html>
+`))
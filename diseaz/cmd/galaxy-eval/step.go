@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tarstars/icfpc2020/diseaz/interpreter"
+)
+
+const stepHelp = `Step commands:
+  s, Enter  step into the next reduction
+  n         step over nested evaluations
+  o         step out of the current evaluation
+  c         continue to the end
+  p         print the current expression in full
+  q         abort the evaluation
+  h         show this help`
+
+// stepWidth is the length of expressions shown while stepping.
+const stepWidth = 160
+
+// stepper pauses evaluation to read step commands.
+type stepper struct {
+	read func(prompt string) (string, error)
+	out  io.Writer
+}
+
+func (s *stepper) show(e *interpreter.TraceEvent) {
+	indent := strings.Repeat("  ", e.Level-1)
+	fmt.Fprintf(s.out, "%6d %s%s %s\n", e.Step, indent, e.Kind, interpreter.Abbrev(e.Token, stepWidth))
+	if e.Result != nil {
+		fmt.Fprintf(s.out, "%6s %s=> %s\n", "", indent, interpreter.Abbrev(e.Result, stepWidth))
+	}
+}
+
+// prompt shows e and returns the command read. Running to the end is the
+// answer at EOF, aborting on other read errors such as Ctrl-C.
+func (s *stepper) prompt(e *interpreter.TraceEvent) interpreter.DebugCommand {
+	s.show(e)
+	for {
+		line, err := s.read("step> ")
+		if err == io.EOF {
+			return interpreter.DebugContinue
+		}
+		if err != nil {
+			return interpreter.DebugAbort
+		}
+		switch strings.TrimSpace(line) {
+		case "", "s":
+			return interpreter.DebugStep
+		case "n":
+			return interpreter.DebugOver
+		case "o":
+			return interpreter.DebugOut
+		case "c":
+			return interpreter.DebugContinue
+		case "q":
+			return interpreter.DebugAbort
+		case "p":
+			fmt.Fprintln(s.out, e.Token.Galaxy())
+			if e.Result != nil {
+				fmt.Fprintf(s.out, "=> %s\n", e.Result.Galaxy())
+			}
+		case "h":
+			fmt.Fprintln(s.out, stepHelp)
+		default:
+			fmt.Fprintf(s.out, "Unknown command %q, h for help\n", line)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/tarstars/icfpc2020/diseaz/optimizer"
+	"github.com/tarstars/icfpc2020/diseaz/strategy"
+)
+
+func main() {
+	strategyName := flag.String("strategy", "hunter",
+		fmt.Sprintf("Strategy to tune, one of %s", strings.Join(strategy.Names(), ", ")))
+	opponents := flag.String("opponents", "", "Comma separated strategies to play against, the tuned one if empty")
+	maxTicks := flag.Int64("max-ticks", 256, "Game length in ticks")
+	maxClones := flag.Int64("max-clones", 1, "Largest number of clones to try")
+	step := flag.Int64("step", 8, "Initial grid step for power and cooling")
+	rounds := flag.Int("rounds", 2, "Rounds of tuning both roles")
+	parallel := flag.Int("parallel", runtime.NumCPU(), "Games to run concurrently")
+	timeout := flag.Duration("timeout", 10*time.Second, "How long the emulator waits for a player")
+	out := flag.String("o", "", "Write the params config for galaxy-bot -params to file, stdout if empty")
+	verbose := flag.Bool("v", false, "Log server traffic")
+	flag.Parse()
+
+	var opps []string
+	if len(*opponents) > 0 {
+		opps = strings.Split(*opponents, ",")
+	}
+	o := optimizer.New(*strategyName, opps...)
+	o.MaxTicks = *maxTicks
+	o.MaxClones = *maxClones
+	o.Step = *step
+	o.Rounds = *rounds
+	o.Parallel = *parallel
+	o.Timeout = *timeout
+	o.Logf = log.New(os.Stderr, "", log.LstdFlags).Printf
+
+	if !*verbose {
+		log.SetOutput(ioutil.Discard)
+	}
+
+	c, err := o.Optimize()
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(*out) > 0 {
+		if err := c.Save(*out); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tarstars/icfpc2020/diseaz/replay"
+)
+
+func main() {
+	out := flag.String("o", "", "Output file, .gif or .svg")
+	format := flag.String("format", "", "Output format, gif or svg; guessed from -o if empty")
+	scale := flag.Int("scale", 3, "GIF pixels per game cell")
+	delay := flag.Int("delay", 5, "GIF frame delay in 1/100 s")
+	tick := flag.Float64("tick", 0.1, "SVG animation seconds per tick")
+	flag.Parse()
+
+	if flag.NArg() != 1 || len(*out) == 0 {
+		log.Fatalf("Usage: %s -o OUTPUT REPLAY", os.Args[0])
+	}
+	if len(*format) == 0 {
+		*format = strings.TrimPrefix(filepath.Ext(*out), ".")
+	}
+	if *scale < 1 {
+		log.Fatalf("Bad scale: %d", *scale)
+	}
+
+	r, err := replay.Load(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch *format {
+	case "gif":
+		err = replay.RenderGIF(f, r, *scale, *delay)
+	case "svg":
+		err = replay.RenderSVG(f, r, *tick)
+	default:
+		log.Fatalf("Unknown format %q", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if r.Outcome != nil {
+		log.Printf("%s won at tick %d", r.Outcome.Winner, r.Outcome.Ticks)
+	}
+}
@@ -0,0 +1,140 @@
+package emulator
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+	"github.com/tarstars/icfpc2020/diseaz/strategy"
+)
+
+func TestGame(t *testing.T) {
+	s := NewServer(32, time.Second)
+	s.Quiet = true
+
+	attacker, err := strategy.New("orbit")
+	require.NoError(t, err)
+	defender, err := strategy.New("orbit")
+	require.NoError(t, err)
+	var responses [2]int
+	winner, ticks, err := s.PlayMatch(attacker, defender, func(role Role, name string, resp gx.Token, gr *proto.GameResponse) {
+		assert.NotNil(t, gr)
+		responses[role]++
+	})
+	require.NoError(t, err)
+	assert.Equal(t, proto.RoleDefender, winner)
+	assert.Equal(t, int64(32), ticks)
+	// JOIN, START and one COMMANDS per tick.
+	assert.Equal(t, [2]int{34, 34}, responses)
+	// Finished games are forgotten.
+	assert.Empty(t, s.games)
+}
+
+func TestLostResponse(t *testing.T) {
+	s := NewServer(8, time.Second)
+	s.Quiet = true
+	attackerKey, defenderKey := s.Create()
+
+	empty := proto.Modulate(proto.Commands{PlayerKey: attackerKey})
+	var sent []string
+	lost := -1
+	lossy := gx.FuncTransport(func(message string) (string, error) {
+		sent = append(sent, message)
+		r, err := s.Send(message)
+		if lost < 0 && len(sent) > 2 && message != empty {
+			// The server ran the commands, but the answer is lost.
+			lost = len(sent) - 1
+			return "", errors.New("connection reset")
+		}
+		return r, err
+	})
+
+	errs := make(chan error, 2)
+	for _, p := range []*strategy.Player{
+		{Ctx: gx.NewContext(nil, gx.WithTransport(lossy)), PlayerKey: attackerKey, Strategy: thrust{}},
+		{Ctx: gx.NewContext(nil, gx.WithTransport(s)), PlayerKey: defenderKey, Strategy: strategy.Idle{}},
+	} {
+		p := p
+		go func() {
+			_, err := p.Play()
+			errs <- err
+		}()
+	}
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+
+	require.Greater(t, lost, 0)
+	require.Greater(t, len(sent), lost+1)
+	assert.Equal(t, empty, sent[lost+1], "commands are not sent twice")
+}
+
+// thrust accelerates its ships every tick.
+type thrust struct{}
+
+func (thrust) Start(info *proto.GameStaticInfo) sim.Params {
+	return sim.Params{Fuel: 100, Power: 1, Cooling: 1, Clones: 1}
+}
+
+func (thrust) Commands(info *proto.GameStaticInfo, state *proto.GameState) []proto.Command {
+	var cmds []proto.Command
+	for _, s := range strategy.MyShips(info, state) {
+		cmds = append(cmds, proto.Accelerate{ShipID: s.Ship.ID, Vec: sim.Vec{X: 1}})
+	}
+	return cmds
+}
+
+func TestStartPosition(t *testing.T) {
+	for _, role := range []Role{RoleAttacker, RoleDefender} {
+		s := sim.Ship{Position: StartPosition(role), Params: sim.Params{Fuel: 1}}
+		assert.False(t, world.Step(s, sim.Vec{}).Destroyed, role)
+	}
+}
+
+func TestRandomStarts(t *testing.T) {
+	assert.Equal(t, DefaultStarts(), RandomStarts(rand.New(rand.NewSource(1)), 0))
+	seen := make(map[Starts]bool)
+	for seed := int64(0); seed < 16; seed++ {
+		ss := RandomStarts(rand.New(rand.NewSource(seed)), 4)
+		assert.Equal(t, ss, RandomStarts(rand.New(rand.NewSource(seed)), 4))
+		seen[ss] = true
+		for _, role := range []Role{RoleAttacker, RoleDefender} {
+			d := ss[role].Sub(StartPosition(role))
+			assert.True(t, d.X >= -4 && d.X <= 4 && d.Y >= -4 && d.Y <= 4, "%v", ss)
+			s := sim.Ship{Position: ss[role], Params: sim.Params{Fuel: 1}}
+			assert.False(t, world.Step(s, sim.Vec{}).Destroyed, ss)
+		}
+	}
+	assert.Greater(t, len(seen), 1)
+}
+
+func TestBadRequests(t *testing.T) {
+	s := NewServer(32, time.Second)
+	s.Quiet = true
+
+	rejected := gx.ModulateToken(gx.List(gx.Int{V: 0}))
+	for _, req := range []proto.Request{
+		proto.Join{PlayerKey: 42},
+		proto.Start{PlayerKey: 1000},
+	} {
+		r, err := s.Send(proto.Modulate(req))
+		require.NoError(t, err)
+		assert.Equal(t, rejected, r)
+	}
+
+	_, err := s.Send("2")
+	assert.Error(t, err)
+
+	// A fuel of 2^64 must not start the ship with no fuel.
+	huge := gx.NewBigInt(new(big.Int).Lsh(big.NewInt(1), 64))
+	attackerKey, _ := s.Create()
+	r := s.Handle(gx.List(gx.Int{V: 3}, gx.Int{V: attackerKey}, gx.List(huge, gx.Int{V: 0}, gx.Int{V: 0}, gx.Int{V: 1})))
+	assert.Equal(t, errorResponse, r)
+}
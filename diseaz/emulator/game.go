@@ -0,0 +1,362 @@
+// Package emulator runs alien battle games like the alien server.
+package emulator
+
+import (
+	"math/rand"
+
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+// Ship parameter limits of emulated games.
+const (
+	MaxPoints = 512
+	MaxHeat   = 64
+)
+
+type Role = proto.Role
+type GameStage = proto.GameStage
+
+const (
+	RoleAttacker = proto.RoleAttacker
+	RoleDefender = proto.RoleDefender
+
+	GamePending  = proto.GamePending
+	GameStarted  = proto.GameStarted
+	GameFinished = proto.GameFinished
+)
+
+var world = sim.DefaultWorld
+
+var defaultParams = sim.Params{Fuel: 1, Power: 1, Cooling: 1, Clones: 1}
+
+type ship struct {
+	sim.Ship
+	Role    Role
+	ID      int64
+	Applied []proto.AppliedCommand
+}
+
+func (s *ship) State() *proto.ShipAndCommands {
+	return &proto.ShipAndCommands{
+		Ship:     proto.ShipState{Role: s.Role, ID: s.ID, Ship: s.Ship, MaxThrust: sim.MaxThrust},
+		Commands: s.Applied,
+	}
+}
+
+type player struct {
+	Key       int64
+	Role      Role
+	Joined    bool
+	Started   bool
+	Params    sim.Params
+	Commands  []gx.Token
+	Submitted bool
+}
+
+type game struct {
+	Players  [2]*player
+	Stage    GameStage
+	Tick     int64
+	MaxTicks int64
+	Starts   Starts
+	Ships    []*ship
+	Winner   Role
+	nextID   int64
+	changed  chan struct{}
+}
+
+func newGame(attackerKey, defenderKey, maxTicks int64, starts Starts) *game {
+	return &game{
+		Players: [2]*player{
+			{Key: attackerKey, Role: RoleAttacker},
+			{Key: defenderKey, Role: RoleDefender},
+		},
+		MaxTicks: maxTicks,
+		Starts:   starts,
+		changed:  make(chan struct{}),
+	}
+}
+
+func (g *game) player(key int64) *player {
+	for _, p := range g.Players {
+		if p.Key == key {
+			return p
+		}
+	}
+	return nil
+}
+
+// notify wakes up every request waiting for this game.
+func (g *game) notify() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+func (g *game) addShip(role Role, pos sim.Vec, params sim.Params) *ship {
+	s := &ship{
+		Ship: sim.Ship{Position: pos, Params: params, MaxHeat: MaxHeat},
+		Role: role,
+		ID:   g.nextID,
+	}
+	g.nextID++
+	g.Ships = append(g.Ships, s)
+	return s
+}
+
+// StartPosition returns where the first ship of role appears.
+func StartPosition(role Role) sim.Vec {
+	if role == RoleAttacker {
+		return sim.Vec{X: -16, Y: 48}
+	}
+	return sim.Vec{X: 16, Y: -48}
+}
+
+// Starts are the positions of the first ships by role.
+type Starts [2]sim.Vec
+
+// DefaultStarts returns the start positions of the alien server.
+func DefaultStarts() Starts {
+	return Starts{
+		RoleAttacker: StartPosition(RoleAttacker),
+		RoleDefender: StartPosition(RoleDefender),
+	}
+}
+
+// RandomStarts moves the default start positions by up to jitter along
+// each axis.
+func RandomStarts(r *rand.Rand, jitter int64) Starts {
+	ss := DefaultStarts()
+	if jitter <= 0 {
+		return ss
+	}
+	for i := range ss {
+		ss[i].X += r.Int63n(2*jitter+1) - jitter
+		ss[i].Y += r.Int63n(2*jitter+1) - jitter
+	}
+	return ss
+}
+
+func (g *game) start() {
+	g.addShip(RoleDefender, g.Starts[RoleDefender], g.Players[RoleDefender].Params)
+	g.addShip(RoleAttacker, g.Starts[RoleAttacker], g.Players[RoleAttacker].Params)
+	g.Stage = GameStarted
+	g.notify()
+}
+
+func (g *game) ship(id int64, role Role) *ship {
+	for _, s := range g.Ships {
+		if s.ID == id && s.Role == role && !s.Destroyed {
+			return s
+		}
+	}
+	return nil
+}
+
+// shot is a laser shot resolved after the ships have moved.
+type shot struct {
+	From   *ship
+	Target sim.Vec
+	Power  int64
+	// Applied is the index of the shot in From.Applied.
+	Applied int
+}
+
+// actions collects the effects of the commands of one tick.
+type actions struct {
+	Thrust     map[*ship]sim.Vec
+	Shots      []shot
+	Detonating []*ship
+}
+
+// step applies the submitted commands and advances the game by one tick.
+func (g *game) step() {
+	acts := actions{Thrust: make(map[*ship]sim.Vec)}
+	for _, s := range g.Ships {
+		s.Applied = nil
+	}
+	for _, p := range g.Players {
+		for _, cmd := range p.Commands {
+			g.apply(p.Role, cmd, &acts)
+		}
+		p.Commands, p.Submitted = nil, false
+	}
+
+	for _, s := range g.Ships {
+		s.Ship = world.Step(s.Ship, acts.Thrust[s])
+	}
+
+	for _, d := range acts.Detonating {
+		for _, s := range g.Ships {
+			if s != d {
+				s.TakeDamage(sim.DetonationDamage(s.Position.Sub(d.Position).Norm()))
+			}
+		}
+	}
+	for _, sh := range acts.Shots {
+		full := sim.ShotDamage(sh.From.Position, sh.Target, sh.Power)
+		var total int64
+		for _, s := range g.Ships {
+			if s.Role != sh.From.Role && !s.Destroyed {
+				d := sim.MissDamage(full, s.Position.Sub(sh.Target).Norm())
+				s.TakeDamage(d)
+				total += d
+			}
+		}
+		sh.From.Applied[sh.Applied].Values[1] = total
+	}
+
+	g.Tick++
+	g.finishIfDone()
+	g.notify()
+}
+
+func (g *game) apply(role Role, cmd gx.Token, acts *actions) {
+	items, err := gx.ListItems(cmd)
+	if err != nil || len(items) < 2 {
+		return
+	}
+	kind, ok1 := intValue(items[0])
+	id, ok2 := intValue(items[1])
+	if !ok1 || !ok2 {
+		return
+	}
+	s := g.ship(id, role)
+	if s == nil {
+		return
+	}
+	switch kind {
+	case 0: // accelerate
+		if len(items) < 3 {
+			return
+		}
+		v, ok := parseVec(items[2])
+		if !ok || !s.CanAccelerate(v) {
+			return
+		}
+		acts.Thrust[s] = v
+		s.Applied = append(s.Applied, proto.AppliedCommand{Type: proto.CommandAccelerate, Vec: v})
+	case 1: // detonate
+		s.Destroyed = true
+		acts.Detonating = append(acts.Detonating, s)
+		s.Applied = append(s.Applied, proto.AppliedCommand{Type: proto.CommandDetonate})
+	case 2: // shoot
+		if len(items) < 4 {
+			return
+		}
+		target, ok := parseVec(items[2])
+		power, okp := intValue(items[3])
+		if !ok || !okp || !s.CanShoot(power) {
+			return
+		}
+		s.Shoot(power)
+		s.Applied = append(s.Applied, proto.AppliedCommand{
+			Type:   proto.CommandShoot,
+			Vec:    target,
+			Values: []int64{power, 0, s.Heat},
+		})
+		acts.Shots = append(acts.Shots, shot{
+			From:    s,
+			Target:  target,
+			Power:   power,
+			Applied: len(s.Applied) - 1,
+		})
+	case 3: // fork
+		if len(items) < 3 {
+			return
+		}
+		ps, err := gx.ListItems(items[2])
+		if err != nil || len(ps) != 4 || s.Params.Clones <= 0 {
+			return
+		}
+		var vs [4]int64
+		for i, p := range ps {
+			v, ok := intValue(p)
+			if !ok || v < 0 {
+				return
+			}
+			vs[i] = v
+		}
+		child := sim.Params{Fuel: vs[0], Power: vs[1], Cooling: vs[2], Clones: vs[3]}
+		if child.Fuel > s.Params.Fuel || child.Power > s.Params.Power ||
+			child.Cooling > s.Params.Cooling || child.Clones >= s.Params.Clones {
+			return
+		}
+		s.Params.Fuel -= child.Fuel
+		s.Params.Power -= child.Power
+		s.Params.Cooling -= child.Cooling
+		s.Params.Clones -= child.Clones + 1
+		c := g.addShip(s.Role, s.Position, child)
+		c.Velocity = s.Velocity
+		s.Applied = append(s.Applied, proto.AppliedCommand{Type: proto.CommandFork, Params: child})
+	}
+}
+
+func parseVec(t gx.Token) (sim.Vec, bool) {
+	c, ok := t.(gx.ICons)
+	if !ok || c.IsNil() {
+		return sim.Vec{}, false
+	}
+	x, okx := intValue(c.Car())
+	y, oky := intValue(c.Cdr())
+	if !okx || !oky {
+		return sim.Vec{}, false
+	}
+	return sim.Vec{X: x, Y: y}, true
+}
+
+// intValue returns the value of an Int token. Big ints are out of range of
+// every request field and are rejected like the other malformed values.
+func intValue(t gx.Token) (int64, bool) {
+	v, ok := t.(gx.Int)
+	if !ok || v.IsBig() {
+		return 0, false
+	}
+	return v.V, true
+}
+
+func (g *game) alive(role Role) bool {
+	for _, s := range g.Ships {
+		if !s.Destroyed && s.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *game) finishIfDone() {
+	switch {
+	case !g.alive(RoleDefender):
+		g.Stage, g.Winner = GameFinished, RoleAttacker
+	case !g.alive(RoleAttacker) || g.Tick >= g.MaxTicks:
+		g.Stage, g.Winner = GameFinished, RoleDefender
+	}
+}
+
+// Response returns the game as seen by role.
+func (g *game) Response(role Role) *proto.GameResponse {
+	gr := &proto.GameResponse{
+		Stage: g.Stage,
+		StaticInfo: &proto.GameStaticInfo{
+			MaxTicks:    g.MaxTicks,
+			Role:        role,
+			Constraints: proto.Constraints{MaxPoints: MaxPoints, Reserved: 1, MaxHeat: MaxHeat},
+			World:       world,
+		},
+	}
+	if defender := g.Players[RoleDefender]; role == RoleAttacker && defender.Started {
+		enemy := defender.Params
+		gr.StaticInfo.Enemy = &enemy
+	}
+
+	if g.Stage != GamePending {
+		gr.State = &proto.GameState{Tick: g.Tick, World: world}
+		for _, s := range g.Ships {
+			if !s.Destroyed {
+				gr.State.Ships = append(gr.State.Ships, s.State())
+			}
+		}
+	}
+	return gr
+}
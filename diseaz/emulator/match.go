@@ -0,0 +1,53 @@
+package emulator
+
+import (
+	"errors"
+
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/strategy"
+)
+
+// ResponseFunc is called with every response to the player of role.
+type ResponseFunc func(role Role, name string, resp gx.Token, gr *proto.GameResponse)
+
+// PlayMatch creates a game and plays it between attacker and defender. It
+// returns the outcome and the first error of either player.
+func (s *Server) PlayMatch(attacker, defender strategy.Strategy, onResponse ResponseFunc) (winner Role, ticks int64, err error) {
+	return s.PlayMatchFrom(DefaultStarts(), attacker, defender, onResponse)
+}
+
+// PlayMatchFrom is PlayMatch with the first ships starting at starts.
+func (s *Server) PlayMatchFrom(starts Starts, attacker, defender strategy.Strategy, onResponse ResponseFunc) (winner Role, ticks int64, err error) {
+	g := s.create(starts)
+
+	errs := make(chan error, 2)
+	for role, st := range map[Role]strategy.Strategy{RoleAttacker: attacker, RoleDefender: defender} {
+		p := &strategy.Player{
+			Ctx:       gx.NewContext(nil, gx.WithTransport(s)),
+			PlayerKey: g.Players[role].Key,
+			Strategy:  st,
+		}
+		if onResponse != nil {
+			role := role
+			p.OnResponse = func(name string, resp gx.Token, gr *proto.GameResponse) {
+				onResponse(role, name, resp, gr)
+			}
+		}
+		go func() {
+			_, err := p.Play()
+			errs <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	winner, ticks, ok := s.result(g)
+	if !ok && err == nil {
+		err = errors.New("Game did not finish")
+	}
+	return winner, ticks, err
+}
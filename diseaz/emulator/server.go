@@ -0,0 +1,254 @@
+package emulator
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+// Server emulates /aliens/send of the alien server.
+type Server struct {
+	// Quiet disables logging of requests and responses.
+	Quiet    bool
+	mu       sync.Mutex
+	games    map[int64]*game
+	nextKey  int64
+	maxTicks int64
+	timeout  time.Duration
+}
+
+// NewServer creates a server running games of maxTicks ticks. Players that do
+// not answer within timeout are skipped.
+func NewServer(maxTicks int64, timeout time.Duration) *Server {
+	return &Server{
+		games:    make(map[int64]*game),
+		nextKey:  1000,
+		maxTicks: maxTicks,
+		timeout:  timeout,
+	}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if !s.Quiet {
+		log.Printf(format, args...)
+	}
+}
+
+var errorResponse = gx.List(gx.Int{V: 0})
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := s.Send(strings.TrimSpace(string(body)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(resp))
+}
+
+// Send answers a modulated request, so the server can be used in-process as
+// an interpreter.Transport.
+func (s *Server) Send(message string) (string, error) {
+	req, err := gx.TryDemodulateToken(message)
+	if err != nil {
+		return "", err
+	}
+	s.logf("Request: %s", req.Galaxy())
+	resp := s.Handle(req)
+	s.logf("Response: %s", resp.Galaxy())
+	return gx.ModulateToken(resp), nil
+}
+
+// Handle answers one demodulated request.
+func (s *Server) Handle(req gx.Token) gx.Token {
+	items, err := gx.ListItems(req)
+	if err != nil || len(items) == 0 {
+		return errorResponse
+	}
+	kind, ok := intValue(items[0])
+	if !ok {
+		return errorResponse
+	}
+
+	switch kind {
+	case 0:
+		return gx.List(gx.Int{V: 1})
+	case 1:
+		attacker, defender := s.Create()
+		return gx.List(
+			gx.Int{V: 1},
+			gx.List(
+				gx.List(gx.Ints(int64(RoleAttacker), attacker)...),
+				gx.List(gx.Ints(int64(RoleDefender), defender)...),
+			),
+		)
+	}
+
+	if len(items) < 2 {
+		return errorResponse
+	}
+	key, ok := intValue(items[1])
+	if !ok {
+		return errorResponse
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g := s.games[key]
+	if g == nil {
+		return errorResponse
+	}
+	p := g.player(key)
+
+	switch kind {
+	case 2:
+		return s.join(g, p)
+	case 3:
+		if len(items) < 3 {
+			return errorResponse
+		}
+		return s.start(g, p, items[2])
+	case 4:
+		if len(items) < 3 {
+			return errorResponse
+		}
+		return s.commands(g, p, items[2])
+	}
+	return errorResponse
+}
+
+// Create starts a new game and returns the player keys.
+func (s *Server) Create() (attacker, defender int64) {
+	return s.CreateFrom(DefaultStarts())
+}
+
+// CreateFrom creates a game whose first ships start at starts.
+func (s *Server) CreateFrom(starts Starts) (attacker, defender int64) {
+	g := s.create(starts)
+	return g.Players[RoleAttacker].Key, g.Players[RoleDefender].Key
+}
+
+func (s *Server) create(starts Starts) *game {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attacker, defender := s.nextKey, s.nextKey+1
+	s.nextKey += 2
+	g := newGame(attacker, defender, s.maxTicks, starts)
+	s.games[attacker] = g
+	s.games[defender] = g
+	s.logf("Created game: attacker %d, defender %d", attacker, defender)
+	return g
+}
+
+// result returns the winner and the last tick of g. It returns false if the
+// game has not finished.
+func (s *Server) result(g *game) (winner Role, ticks int64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g.Stage != GameFinished {
+		return 0, 0, false
+	}
+	return g.Winner, g.Tick, true
+}
+
+// wait releases the lock until done returns true or the timeout expires.
+// It reports whether done became true.
+func (s *Server) wait(g *game, done func() bool) bool {
+	deadline := time.After(s.timeout)
+	for !done() {
+		ch := g.changed
+		s.mu.Unlock()
+		select {
+		case <-ch:
+			s.mu.Lock()
+		case <-deadline:
+			s.mu.Lock()
+			return done()
+		}
+	}
+	return true
+}
+
+func (s *Server) join(g *game, p *player) gx.Token {
+	p.Joined = true
+	g.notify()
+	return g.Response(p.Role).Token()
+}
+
+func (s *Server) start(g *game, p *player, paramsTok gx.Token) gx.Token {
+	if g.Stage != GamePending {
+		return g.Response(p.Role).Token()
+	}
+	ps, err := gx.ListItems(paramsTok)
+	if err != nil || len(ps) != 4 {
+		return errorResponse
+	}
+	var vs [4]int64
+	for i, t := range ps {
+		v, ok := intValue(t)
+		if !ok || v < 0 {
+			return errorResponse
+		}
+		vs[i] = v
+	}
+	params := sim.Params{Fuel: vs[0], Power: vs[1], Cooling: vs[2], Clones: vs[3]}
+	if params.Cost() > MaxPoints || params.Clones < 1 {
+		return errorResponse
+	}
+	p.Params, p.Started = params, true
+	g.notify()
+
+	other := g.Players[1-p.Role]
+	if !s.wait(g, func() bool { return other.Started || g.Stage != GamePending }) {
+		s.logf("Player %d did not start in time, using default parameters", other.Key)
+		other.Params, other.Started = defaultParams, true
+	}
+	if g.Stage == GamePending {
+		g.start()
+	}
+	return g.Response(p.Role).Token()
+}
+
+func (s *Server) commands(g *game, p *player, cmdsTok gx.Token) gx.Token {
+	if g.Stage != GameStarted {
+		return g.Response(p.Role).Token()
+	}
+	cmds, err := gx.ListItems(cmdsTok)
+	if err != nil {
+		return errorResponse
+	}
+	tick := g.Tick
+	p.Commands, p.Submitted = cmds, true
+	g.notify()
+
+	other := g.Players[1-p.Role]
+	if !s.wait(g, func() bool { return other.Submitted || g.Tick != tick }) {
+		s.logf("Player %d did not send commands in time", other.Key)
+	}
+	if g.Tick == tick {
+		g.step()
+	}
+	if g.Stage == GameFinished {
+		// Forget the game, the requests waiting for it hold g still.
+		for _, p := range g.Players {
+			delete(s.games, p.Key)
+		}
+	}
+	return g.Response(p.Role).Token()
+}
@@ -2,4 +2,7 @@ module github.com/tarstars/icfpc2020/diseaz
 
 go 1.13
 
-require github.com/stretchr/testify v1.6.1
+require (
+	github.com/peterh/liner v1.2.2
+	github.com/stretchr/testify v1.6.1
+)
@@ -1,11 +1,11 @@
 package interpreter
 
 import (
-	"io/ioutil"
+	"context"
+	"fmt"
+	"io"
 	"log"
-	"net/http"
 	"net/url"
-	"strings"
 )
 
 type Context interface {
@@ -13,10 +13,14 @@ type Context interface {
 	SetVar(n int, v Token)
 
 	Eval(t Token) Token
+	Ap(f, a Token) Token
 
 	Send(message string) string
 	SendToken(v Token) Token
 
+	// Symbols numbers the variable names of the context.
+	Symbols() *Symbols
+
 	Picture() *Picture
 	// OutFormat() OutFormat
 
@@ -33,55 +37,255 @@ const (
 	OutFormatG OutFormat = "g"
 )
 
+// Ctx is the state of evaluation. It is not safe for concurrent use, give
+// every goroutine its own context on shared Definitions instead.
 type Ctx struct {
-	Vars      map[int]Token
-	ServerURL *url.URL
+	Vars map[int]Token
+	// Defs, if set, defines the variables missing in Vars.
+	Defs      *Definitions
+	Transport Transport
 	Pic       *Picture
 	CallLevel int
 	EvalCount int
+	// Lazy enables call-by-need: application nodes and variable
+	// definitions are wrapped into shared thunks and reduced at most once.
+	// It pays off on later evaluations in the same context, which reuse the
+	// reduced definitions. The first galaxy click reduces every definition
+	// it needs anyway and costs about the same as eager evaluation.
+	Lazy bool
+	// Graph makes lazy contexts evaluate with the graph reduction evaluator
+	// instead of the recursive one.
+	Graph bool
+	// Context, if set, stops evaluation and sends once it is done.
+	Context context.Context
+	// MaxSteps limits the reduction steps of one evaluation and MaxDepth the
+	// nesting of evaluations. Zero means no limit.
+	MaxSteps int
+	MaxDepth int
+	// Tracer, if set, receives the steps of evaluation.
+	Tracer Tracer
+
+	graph *graph
+	syms  *Symbols
+	// progs are the parsed sources of the variables that have one.
+	progs map[int]Program
+	steps int
+	trace []Token
+}
+
+// Option configures a context created by NewContext.
+type Option func(c *Ctx)
+
+// WithLimits limits the reduction steps and the nesting of every evaluation.
+func WithLimits(maxSteps, maxDepth int) Option {
+	return func(c *Ctx) {
+		c.MaxSteps = maxSteps
+		c.MaxDepth = maxDepth
+	}
+}
+
+// WithTransport replaces the HTTP transport to serverURL.
+func WithTransport(t Transport) Option {
+	return func(c *Ctx) {
+		c.Transport = t
+	}
+}
+
+// WithRecording records all traffic of the transport chosen so far to w.
+func WithRecording(w io.Writer) Option {
+	return func(c *Ctx) {
+		c.Transport = NewRecordingTransport(c.Transport, w)
+	}
 }
 
-func NewContext(serverURL *url.URL) *Ctx {
-	return &Ctx{
-		Vars:      make(map[int]Token),
-		ServerURL: serverURL,
-		Pic:       NewPicture(),
+// WithLazy switches call-by-need evaluation on or off. Contexts evaluate
+// eagerly by default.
+func WithLazy(lazy bool) Option {
+	return func(c *Ctx) {
+		c.Lazy = lazy
 	}
 }
 
-func (c Ctx) GetVar(n int) Token {
-	p, exists := c.Vars[n]
+// WithGraph makes the context evaluate with the graph reduction evaluator,
+// which is call-by-need.
+func WithGraph(graph bool) Option {
+	return func(c *Ctx) {
+		c.Graph = graph
+		if graph {
+			c.Lazy = true
+		}
+	}
+}
+
+func NewContext(serverURL *url.URL, opts ...Option) *Ctx {
+	c := &Ctx{
+		Vars: make(map[int]Token),
+		Pic:  NewPicture(),
+	}
+	if serverURL != nil {
+		c.Transport = NewHTTPTransport(serverURL)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Symbols returns the variable names of the context. They extend the names of
+// the shared definitions.
+func (c *Ctx) Symbols() *Symbols {
+	if c.syms == nil {
+		var parent *Symbols
+		if c.Defs != nil {
+			parent = c.Defs.syms
+		}
+		c.syms = NewSymbols(parent)
+	}
+	return c.syms
+}
+
+func (c *Ctx) GetVar(n int) Token {
+	p := c.lookup(n)
+	if c.Tracer != nil {
+		c.traceEvent(TraceVar, c.CallLevel, c.Symbols().Var(n), p)
+	}
+	return p
+}
+
+func (c *Ctx) lookup(n int) Token {
+	p, exists := c.definition(n)
 	if !exists {
-		log.Panicf("Variable does not exist: %d", n)
+		panic(&UnboundVariable{N: n, Name: c.Symbols().Var(n).Name})
 	}
 	return p
 }
 
+// defined tells whether variable n is defined, possibly by the shared
+// definitions.
+func (c *Ctx) defined(n int) bool {
+	if _, exists := c.Vars[n]; exists {
+		return true
+	}
+	if c.Defs == nil {
+		return false
+	}
+	_, exists := c.Defs.progs[n]
+	return exists
+}
+
+// definition returns the value of variable n, binding shared definitions on
+// first use.
+func (c *Ctx) definition(n int) (Token, bool) {
+	if p, exists := c.Vars[n]; exists {
+		return p, true
+	}
+	return c.bind(n)
+}
+
+// SetVar defines variable n. It warns when n is already defined.
 func (c *Ctx) SetVar(n int, p Token) {
+	if c.defined(n) {
+		log.Printf("Warning: %s redefined", c.Symbols().Var(n).Galaxy())
+	}
+	if c.Lazy {
+		p = NewThunk(p)
+	}
 	c.Vars[n] = p
+	delete(c.progs, n)
+	// Compiled definitions may refer to the old value.
+	c.graph = nil
+}
+
+// Define defines variable n as program p and keeps p as its source.
+func (c *Ctx) Define(n int, p Program) {
+	tok, err := Interpret(c, p)
+	if err != nil {
+		panic(err)
+	}
+	c.SetVar(n, tok)
+	c.setSource(n, p)
+}
+
+func (c *Ctx) setSource(n int, p Program) {
+	if c.progs == nil {
+		c.progs = make(map[int]Program)
+	}
+	c.progs[n] = p
+}
+
+// Definition returns the unevaluated definition of variable n. Evaluation
+// replaces the values of lazy variables, but not their definitions.
+func (c *Ctx) Definition(n int) (Token, bool) {
+	p, ok := c.progs[n]
+	if !ok {
+		if t, exists := c.Vars[n]; exists {
+			return t, true
+		}
+		if c.Defs == nil {
+			return nil, false
+		}
+		if p, ok = c.Defs.progs[n]; !ok {
+			return nil, false
+		}
+	}
+	tok, err := Interpret(&Ctx{}, p)
+	if err != nil {
+		panic(err)
+	}
+	return tok, true
+}
+
+// Defined returns the defined variables in the order of Symbols.Sort.
+func (c *Ctx) Defined() []int {
+	var ns []int
+	for n := range c.Vars {
+		ns = append(ns, n)
+	}
+	if c.Defs != nil {
+		for n := range c.Defs.progs {
+			if _, exists := c.Vars[n]; !exists {
+				ns = append(ns, n)
+			}
+		}
+	}
+	c.Symbols().Sort(ns)
+	return ns
+}
+
+// Ap builds an application node of f to a.
+func (c *Ctx) Ap(f, a Token) Token {
+	if c.Lazy {
+		return NewThunk(Ap2{F: f, A: a})
+	}
+	return Ap2{F: f, A: a}
 }
 
 func (c *Ctx) SendToken(v Token) Token {
 	return DemodulateToken(c.Send(ModulateToken(v)))
 }
 
+// TrySendToken is SendToken that returns send errors instead of panicking.
+func (c *Ctx) TrySendToken(v Token) (r Token, err error) {
+	defer catchEvalError(&err)
+	return c.SendToken(v), nil
+}
+
 func (c *Ctx) Send(message string) string {
 	log.Printf("Send: %#v", message)
-	res, err := http.Post(c.ServerURL.String(), "text/plain", strings.NewReader(message))
-	if err != nil {
-		log.Panicf("Unexpected server response:\n%v", err)
+	if c.Transport == nil {
+		panic(&SendError{Message: message, Err: fmt.Errorf("no transport")})
+	}
+	ctx := c.Context
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	defer res.Body.Close()
-	body, err := ioutil.ReadAll(res.Body)
+	r, err := SendContext(ctx, c.Transport, message)
 	if err != nil {
-		log.Panicf("Unexpected server response:\n%v", err)
+		panic(&SendError{Message: message, Err: err})
 	}
-
-	if res.StatusCode != http.StatusOK {
-		log.Panicf("Unexpected server response:\nHTTP code: %d\nResponse body: %s", res.StatusCode, body)
+	if c.Tracer != nil {
+		c.traceEvent(TraceSend, c.CallLevel, signalToken(message), signalToken(r))
 	}
-
-	r := string(body)
 	log.Printf("Recv: %#v", r)
 	return r
 }
@@ -100,8 +304,13 @@ func (c *Ctx) Leave() int {
 	return c.CallLevel
 }
 
+// CountEval counts a reduction step. It panics with LimitError if the step
+// exceeds a limit of the context.
 func (c *Ctx) CountEval() int {
-	c.EvalCount++
+	if e := c.step(); e != nil {
+		e.Trace = traceOf(c.trace)
+		panic(e)
+	}
 	return c.EvalCount
 }
 
@@ -0,0 +1,529 @@
+package interpreter
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// TermKind is the kind of a decompiled term.
+type TermKind int
+
+const (
+	// TermPrim is a built-in function such as add or cons.
+	TermPrim TermKind = iota
+	// TermNum is an integer literal.
+	TermNum
+	// TermRef is a reference to a definition :N.
+	TermRef
+	// TermParam is a lambda parameter.
+	TermParam
+	// TermLambda is a function of Params returning Body.
+	TermLambda
+)
+
+// Term is a decompiled expression: a head applied to Args.
+type Term struct {
+	Kind   TermKind
+	Name   string
+	Num    *big.Int
+	Ref    int
+	Params []string
+	Body   *Term
+	Args   []*Term
+}
+
+func prim(name string) *Term {
+	return &Term{Kind: TermPrim, Name: name}
+}
+
+func (t *Term) is(name string) bool {
+	return t.Kind == TermPrim && t.Name == name
+}
+
+// apply returns t applied to more arguments.
+func (t *Term) apply(args ...*Term) *Term {
+	if len(args) == 0 {
+		return t
+	}
+	r := *t
+	r.Args = append(append([]*Term(nil), t.Args...), args...)
+	return &r
+}
+
+// subst replaces the parameter name with v. Parameter names are unique, so
+// there is nothing to capture.
+func (t *Term) subst(name string, v *Term) *Term {
+	var r *Term
+	if t.Kind == TermParam && t.Name == name {
+		r = v.apply()
+	} else {
+		c := *t
+		c.Args = nil
+		if t.Body != nil {
+			c.Body = t.Body.subst(name, v)
+		}
+		r = &c
+	}
+	for _, a := range t.Args {
+		r = r.apply(a.subst(name, v))
+	}
+	return r
+}
+
+// isPair reports whether t is cons or vec of two values.
+func (t *Term) isPair() bool {
+	return (t.is("cons") || t.is("vec")) && len(t.Args) == 2
+}
+
+// arity is the number of arguments of the built-in functions the decompiler
+// knows.
+var arity = map[string]int{
+	"s": 3, "c": 3, "b": 3, "i": 1, "t": 2, "f": 2,
+	"inc": 1, "dec": 1, "neg": 1, "add": 2, "mul": 2, "div": 2, "eq": 2, "lt": 2,
+	"car": 1, "cdr": 1, "isnil": 1, "if0": 3,
+}
+
+// combinators are abstracted into lambdas whenever they lack arguments.
+var combinators = map[string]bool{"s": true, "c": true, "b": true, "i": true}
+
+const (
+	// decompileSteps bounds the reductions of one decompilation.
+	decompileSteps = 100000
+	// decompileDepth bounds the nesting of abstracted lambdas.
+	decompileDepth = 16
+	// decompileParams bounds the parameters of one lambda.
+	decompileParams = 8
+)
+
+type decompiler struct {
+	steps  int
+	params int
+}
+
+func (d *decompiler) fresh() *Term {
+	p := &Term{Kind: TermParam, Name: fmt.Sprintf("x%d", d.params)}
+	d.params++
+	return p
+}
+
+func num(n *big.Int) *Term {
+	return &Term{Kind: TermNum, Num: n}
+}
+
+func boolean(b bool) *Term {
+	if b {
+		return prim("t")
+	}
+	return prim("f")
+}
+
+// nums reduces the first n arguments of t and returns them if they are all
+// literals.
+func (d *decompiler) nums(t *Term, n int) ([]*big.Int, bool) {
+	var ns []*big.Int
+	for i := 0; i < n; i++ {
+		t.Args[i] = d.whnf(t.Args[i])
+		if t.Args[i].Kind != TermNum {
+			return nil, false
+		}
+		ns = append(ns, t.Args[i].Num)
+	}
+	return ns, true
+}
+
+// step performs one reduction at the head of t.
+func (d *decompiler) step(t *Term) (*Term, bool) {
+	a := t.Args
+	if t.Kind == TermLambda && len(a) > 0 {
+		body := t.Body.subst(t.Params[0], a[0])
+		if len(t.Params) > 1 {
+			body = &Term{Kind: TermLambda, Params: t.Params[1:], Body: body}
+		}
+		return body.apply(a[1:]...), true
+	}
+	if (t.is("cons") || t.is("vec")) && len(a) >= 3 {
+		return a[2].apply(a[0], a[1]).apply(a[3:]...), true
+	}
+	if t.is("nil") && len(a) >= 1 {
+		return prim("t").apply(a[1:]...), true
+	}
+	if t.Kind != TermPrim || len(a) < arity[t.Name] {
+		return t, false
+	}
+
+	n := arity[t.Name]
+	rest := a[n:]
+	switch t.Name {
+	case "i":
+		return a[0].apply(rest...), true
+	case "t":
+		return a[0].apply(rest...), true
+	case "f":
+		return a[1].apply(rest...), true
+	case "s":
+		return a[0].apply(a[2], a[1].apply(a[2])).apply(rest...), true
+	case "c":
+		return a[0].apply(a[2], a[1]).apply(rest...), true
+	case "b":
+		return a[0].apply(a[1].apply(a[2])).apply(rest...), true
+	case "car", "cdr":
+		a[0] = d.whnf(a[0])
+		if !a[0].isPair() {
+			return t, false
+		}
+		if t.Name == "car" {
+			return a[0].Args[0].apply(rest...), true
+		}
+		return a[0].Args[1].apply(rest...), true
+	case "isnil":
+		a[0] = d.whnf(a[0])
+		if a[0].is("nil") && len(a[0].Args) == 0 {
+			return prim("t").apply(rest...), true
+		}
+		if a[0].isPair() {
+			return prim("f").apply(rest...), true
+		}
+	case "if0":
+		a[0] = d.whnf(a[0])
+		if a[0].Kind == TermNum {
+			if a[0].Num.Sign() == 0 {
+				return a[1].apply(rest...), true
+			}
+			return a[2].apply(rest...), true
+		}
+	case "inc", "dec", "neg":
+		ns, ok := d.nums(t, 1)
+		if !ok {
+			return t, false
+		}
+		r := new(big.Int)
+		switch t.Name {
+		case "inc":
+			r.Add(ns[0], big.NewInt(1))
+		case "dec":
+			r.Sub(ns[0], big.NewInt(1))
+		case "neg":
+			r.Neg(ns[0])
+		}
+		return num(r).apply(rest...), true
+	case "add", "mul", "div", "eq", "lt":
+		ns, ok := d.nums(t, 2)
+		if !ok || (t.Name == "div" && ns[1].Sign() == 0) {
+			return t, false
+		}
+		var r *Term
+		switch t.Name {
+		case "add":
+			r = num(new(big.Int).Add(ns[0], ns[1]))
+		case "mul":
+			r = num(new(big.Int).Mul(ns[0], ns[1]))
+		case "div":
+			r = num(new(big.Int).Quo(ns[0], ns[1]))
+		case "eq":
+			r = boolean(ns[0].Cmp(ns[1]) == 0)
+		case "lt":
+			r = boolean(ns[0].Cmp(ns[1]) < 0)
+		}
+		return r.apply(rest...), true
+	}
+	return t, false
+}
+
+// whnf reduces the head of t as far as the step budget allows.
+func (d *decompiler) whnf(t *Term) *Term {
+	for d.steps < decompileSteps {
+		r, ok := d.step(t)
+		if !ok {
+			break
+		}
+		d.steps++
+		t = r
+	}
+	return t
+}
+
+// missing returns the number of arguments t lacks to be a value. Booleans and
+// bare functions other than combinators count as values.
+func missing(t *Term) int {
+	if t.Kind != TermPrim {
+		return 0
+	}
+	n, ok := arity[t.Name]
+	if !ok || len(t.Args) >= n || (len(t.Args) == 0 && !combinators[t.Name]) {
+		return 0
+	}
+	return n - len(t.Args)
+}
+
+// abstract applies t to fresh parameters until it is a value and returns the
+// lambda of them. Top-level definitions are abstracted even if they are bare
+// functions.
+func (d *decompiler) abstract(t *Term, depth int, top bool) *Term {
+	var params []string
+	for len(params) < decompileParams {
+		t = d.whnf(t)
+		n := missing(t)
+		if top && t.Kind == TermPrim && len(t.Args) < arity[t.Name] {
+			n = arity[t.Name] - len(t.Args)
+		}
+		if n == 0 {
+			break
+		}
+		p := d.fresh()
+		params = append(params, p.Name)
+		t = t.apply(p)
+		top = false
+	}
+	body := d.normalize(t, depth+1)
+	if len(params) == 0 {
+		return body
+	}
+	if body.Kind == TermLambda && len(body.Args) == 0 {
+		params = append(params, body.Params...)
+		body = body.Body
+	}
+	return &Term{Kind: TermLambda, Params: params, Body: body}
+}
+
+// normalize reduces t and its arguments, turning partial applications into
+// lambdas.
+func (d *decompiler) normalize(t *Term, depth int) *Term {
+	t = d.whnf(t)
+	if missing(t) > 0 && depth < decompileDepth {
+		return d.abstract(t, depth, false)
+	}
+	r := *t
+	r.Args = nil
+	for _, a := range t.Args {
+		r.Args = append(r.Args, d.normalize(a, depth))
+	}
+	return &r
+}
+
+// term converts the token tree of an expression into a term. Evaluated
+// thunks are decompiled from their values.
+func term(t Token) *Term {
+	switch t := t.(type) {
+	case *Thunk:
+		return term(t.T)
+	case Ap2:
+		return term(t.F).apply(term(t.A))
+	case VarN:
+		return &Term{Kind: TermRef, Ref: t.N, Name: t.Name}
+	case Int:
+		return num(t.Big())
+	case Vec:
+		return prim("vec")
+	case Modulate1:
+		return prim("mod").apply(term(t.X0))
+	case Demodulate1:
+		return prim("dem").apply(term(t.X0))
+	case Send1:
+		return prim("send").apply(term(t.X0))
+	case Draw1:
+		return prim("draw").apply(term(t.X0))
+	case Multipledraw1:
+		return prim("multipledraw").apply(term(t.X0))
+	}
+	if o, args, ok := primOf(t); ok {
+		r := prim(opTokens[o].Galaxy())
+		for _, a := range args {
+			r = r.apply(term(a))
+		}
+		return r
+	}
+	return prim(t.Galaxy())
+}
+
+// DecompileToken abstracts a combinator expression back into lambda terms.
+func DecompileToken(t Token) *Term {
+	d := &decompiler{}
+	return d.abstract(term(t), 0, true)
+}
+
+// Decompile abstracts the definition of n back into lambda terms. Other
+// definitions are referenced, not inlined.
+func (c *Ctx) Decompile(n int) (*Term, error) {
+	t, ok := c.Definition(n)
+	if !ok {
+		return nil, &UnboundVariable{N: n, Name: c.Symbols().Var(n).Name}
+	}
+	return DecompileToken(t), nil
+}
+
+// termWidth is the line width above which String breaks lambdas, conditions
+// and lists into lines.
+const termWidth = 100
+
+var binaryOps = map[string]string{"add": "+", "mul": "*", "div": "/", "eq": "==", "lt": "<"}
+
+// isList reports whether t is a cons chain ending in nil.
+func (t *Term) isList() bool {
+	for ; t.isPair(); t = t.Args[1] {
+	}
+	return t.is("nil") && len(t.Args) == 0
+}
+
+// items returns the elements of a list.
+func (t *Term) items() []*Term {
+	var items []*Term
+	for ; t.isPair(); t = t.Args[1] {
+		items = append(items, t.Args[0])
+	}
+	return items
+}
+
+// condition splits a saturated boolean choice into its parts.
+func (t *Term) condition() (cond string, then, els *Term, ok bool) {
+	if t.Kind != TermPrim {
+		return "", nil, nil, false
+	}
+	a := t.Args
+	switch {
+	case t.Name == "if0" && len(a) == 3:
+		return operand(a[0]) + " == 0", a[1], a[2], true
+	case (t.Name == "eq" || t.Name == "lt") && len(a) == 4:
+		return operand(a[0]) + " " + binaryOps[t.Name] + " " + operand(a[1]), a[2], a[3], true
+	case t.Name == "isnil" && len(a) == 3:
+		return "isnil(" + a[0].inline() + ")", a[1], a[2], true
+	}
+	return "", nil, nil, false
+}
+
+// isOperator reports whether t prints with an infix or prefix operator.
+func (t *Term) isOperator() bool {
+	if t.Kind == TermLambda {
+		return true
+	}
+	if t.Kind != TermPrim {
+		return false
+	}
+	if _, _, _, ok := t.condition(); ok {
+		return true
+	}
+	_, bin := binaryOps[t.Name]
+	return (bin && len(t.Args) == 2) || ((t.Name == "inc" || t.Name == "dec" || t.Name == "neg") && len(t.Args) == 1)
+}
+
+// operand prints t as an operand of an operator.
+func operand(t *Term) string {
+	if t.isOperator() {
+		return "(" + t.inline() + ")"
+	}
+	return t.inline()
+}
+
+func inlineAll(ts []*Term) string {
+	var ss []string
+	for _, t := range ts {
+		ss = append(ss, t.inline())
+	}
+	return strings.Join(ss, ", ")
+}
+
+// call prints head applied to args, splitting off the arguments the head
+// does not take.
+func (t *Term) call(n int, head func(args []*Term) string) string {
+	if len(t.Args) <= n {
+		return head(t.Args)
+	}
+	return "(" + head(t.Args[:n]) + ")(" + inlineAll(t.Args[n:]) + ")"
+}
+
+// inline prints t on one line.
+func (t *Term) inline() string {
+	var head string
+	switch t.Kind {
+	case TermNum:
+		head = t.Num.String()
+	case TermRef:
+		head = VarN{N: t.Ref, Name: t.Name}.Galaxy()
+	case TermParam:
+		head = t.Name
+	case TermLambda:
+		head = "fn(" + strings.Join(t.Params, ", ") + ") => " + t.Body.inline()
+		if len(t.Args) > 0 {
+			head = "(" + head + ")"
+		}
+	case TermPrim:
+		return t.inlinePrim()
+	}
+	if len(t.Args) == 0 {
+		return head
+	}
+	return head + "(" + inlineAll(t.Args) + ")"
+}
+
+func (t *Term) inlinePrim() string {
+	a := t.Args
+	if cond, then, els, ok := t.condition(); ok {
+		return "if " + cond + " then " + then.inline() + " else " + els.inline()
+	}
+	switch {
+	case len(a) == 0:
+		switch t.Name {
+		case "t":
+			return "true"
+		case "f":
+			return "false"
+		case "nil":
+			return "[]"
+		}
+		return t.Name
+	case t.isList():
+		return "[" + inlineAll(t.items()) + "]"
+	case t.isPair():
+		return "(" + inlineAll(a) + ")"
+	}
+	if op, ok := binaryOps[t.Name]; ok && len(a) >= 2 {
+		return t.call(2, func(a []*Term) string { return operand(a[0]) + " " + op + " " + operand(a[1]) })
+	}
+	switch t.Name {
+	case "inc":
+		return t.call(1, func(a []*Term) string { return operand(a[0]) + " + 1" })
+	case "dec":
+		return t.call(1, func(a []*Term) string { return operand(a[0]) + " - 1" })
+	case "neg":
+		return t.call(1, func(a []*Term) string { return "-" + operand(a[0]) })
+	}
+	return t.Name + "(" + inlineAll(a) + ")"
+}
+
+// format prints t at indent, breaking it into lines if it is too long.
+func (t *Term) format(indent string) string {
+	s := t.inline()
+	if len(indent)+len(s) <= termWidth {
+		return s
+	}
+	return t.block(indent)
+}
+
+// block prints t broken into lines at indent. The else branches of a
+// condition chain are broken too.
+func (t *Term) block(indent string) string {
+	in := indent + "  "
+	if t.Kind == TermLambda && len(t.Args) == 0 {
+		return "fn(" + strings.Join(t.Params, ", ") + ") =>\n" + in + t.Body.format(in)
+	}
+	if cond, then, els, ok := t.condition(); ok {
+		r := "if " + cond + " then\n" + in + then.format(in) + "\n" + indent + "else"
+		if _, _, _, chain := els.condition(); chain {
+			return r + " " + els.block(indent)
+		}
+		return r + "\n" + in + els.format(in)
+	}
+	if t.isList() && len(t.Args) > 0 {
+		var items []string
+		for _, item := range t.items() {
+			items = append(items, in+item.format(in))
+		}
+		return "[\n" + strings.Join(items, ",\n") + "\n" + indent + "]"
+	}
+	return t.inline()
+}
+
+// String prints the term as pseudo-code.
+func (t *Term) String() string {
+	return t.format("")
+}
@@ -0,0 +1,114 @@
+package interpreter
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decompileString(t *testing.T, program string) string {
+	c := NewContext(nil)
+	_, err := TryParseString(c, program)
+	require.NoError(t, err)
+	term, err := c.Decompile(1)
+	require.NoError(t, err)
+	return term.String()
+}
+
+func TestDecompile(t *testing.T) {
+	for _, tc := range []struct {
+		program  string
+		expected string
+	}{
+		{":1 = ap ap c add 1", "fn(x0) => x0 + 1"},
+		{":1 = ap ap b inc dec", "fn(x0) => (x0 - 1) + 1"},
+		{":1 = add", "fn(x0, x1) => x0 + x1"},
+		{":1 = ap ap s mul i", "fn(x0) => x0 * x0"},
+		{":1 = ap ap cons 1 ap ap cons ap ap vec 2 3 nil", "[1, (2, 3)]"},
+		{":1 = ap ap c ap ap c if0 1 2", "fn(x0) => if x0 == 0 then 1 else 2"},
+		{":1 = ap ap b ap add 2 ap mul 3", "fn(x0) => 2 + (3 * x0)"},
+		{":1 = ap ap add 2 ap ap mul 3 4", "14"},
+		{":1 = ap car ap ap cons :2 nil", ":2"},
+		{":1 = ap t :2", "fn(x0) => :2"},
+		{":1 = ap ap s ap ap c isnil 0 ap ap c ap ap b add car 1", "fn(x0) => if isnil(x0) then 0 else car(x0) + 1"},
+		// pwr2 from the galaxy messages.
+		{":1 = ap ap s ap ap c ap eq 0 1 ap ap b ap mul 2 ap ap b :1 ap add -1",
+			"fn(x0) => if 0 == x0 then 1 else 2 * :1(-1 + x0)"},
+		// Nested partial applications become inner lambdas.
+		{":1 = ap :2 ap s t", ":2(fn(x0, x1) => x1)"},
+		{":1 = ap ap c ap ap b cons ap c cons nil", "fn(x0) => [fn(x1) => (x1, x0)]"},
+	} {
+		assert.Equal(t, tc.expected, decompileString(t, tc.program), tc.program)
+	}
+}
+
+func TestDecompileIfChain(t *testing.T) {
+	assert.Equal(t, `if :1000000000 == 0 then
+  :2000000000
+else if :3000000000 == 0 then
+  :4000000000
+else
+  [:5000000000, :6000000000, :7000000000]`,
+		decompileString(t, ":1 = ap ap ap if0 :1000000000 :2000000000 ap ap ap if0 :3000000000 :4000000000 "+
+			"ap ap cons :5000000000 ap ap cons :6000000000 ap ap cons :7000000000 nil"))
+}
+
+func TestDecompileEvaluated(t *testing.T) {
+	for _, lazy := range []bool{false, true} {
+		c := NewContext(nil, WithLazy(lazy))
+		tok, err := TryParseString(c, ":2 = 5\n:1 = ap inc :2\nap inc :1")
+		require.NoError(t, err)
+		require.Equal(t, []Token{Int{V: 7}}, tok)
+		term, err := c.Decompile(1)
+		require.NoError(t, err)
+		assert.Equal(t, ":2 + 1", term.String(), "lazy %v", lazy)
+	}
+}
+
+func TestDecompileNamed(t *testing.T) {
+	const pw = "pw = ap ap s ap ap c ap eq 0 1 ap ap b ap mul 2 ap ap b pw ap add -1\n"
+	d, err := NewDefinitions(strings.NewReader(pw))
+	require.NoError(t, err)
+	c := NewContext(nil, WithDefinitions(d))
+	_, err = TryParseString(c, "twice = ap ap b ap mul 2 pw")
+	require.NoError(t, err)
+
+	for name, expected := range map[string]string{
+		"pw":    "fn(x0) => if 0 == x0 then 1 else 2 * pw(-1 + x0)",
+		"twice": "fn(x0) => 2 * pw(x0)",
+	} {
+		v, ok := c.Symbols().Lookup(name)
+		require.True(t, ok, name)
+		term, err := c.Decompile(v.N)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, term.String(), name)
+	}
+}
+
+func TestDecompileErrors(t *testing.T) {
+	c := NewContext(nil)
+	_, err := c.Decompile(1)
+	assert.IsType(t, &UnboundVariable{}, err)
+}
+
+func TestDecompileGalaxy(t *testing.T) {
+	f, err := os.Open("../galaxy.txt")
+	require.NoError(t, err)
+	defer f.Close()
+	c := NewContext(nil)
+	_, err = TryParseReader(c, f)
+	require.NoError(t, err)
+
+	for n := range c.Vars {
+		term, err := c.Decompile(n)
+		require.NoError(t, err, ":%d", n)
+		assert.NotEmpty(t, term.String(), ":%d", n)
+	}
+	term, err := c.Decompile(1338)
+	require.NoError(t, err)
+	assert.Equal(t, TermLambda, term.Kind)
+	assert.Equal(t, []string{"x0", "x1"}, term.Params)
+}
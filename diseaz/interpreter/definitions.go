@@ -0,0 +1,102 @@
+package interpreter
+
+import (
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Definitions are galaxy definitions shared by many contexts. They are kept
+// as parsed programs and never change after loading, so contexts on
+// different goroutines may use them concurrently. Every context builds its
+// own expressions of the definitions it uses, evaluation state is never
+// shared.
+type Definitions struct {
+	progs map[int]Program
+	syms  *Symbols
+}
+
+// NewDefinitions parses `:N = expr` and `name = expr` lines of rd. Other lines than
+// definitions and comments are errors. Names may be used before their
+// definitions.
+func NewDefinitions(rd io.Reader) (d *Definitions, err error) {
+	defer catchEvalError(&err)
+	d = &Definitions{progs: make(map[int]Program), syms: NewSymbols(nil)}
+	lines := readLines(rd)
+	declare(d.syms, lines)
+	for _, line := range lines {
+		toks := strings.Fields(line)
+		if len(toks) == 0 || IsComment(toks[0]) {
+			continue
+		}
+		v, body, ok := splitAssign(d.syms, toks)
+		if !ok {
+			return nil, &ParseError{Text: strings.TrimSpace(line), Reason: "not a definition"}
+		}
+		p := ParseProgram(d.syms, body)
+		if _, err := Interpret(&Ctx{}, p); err != nil {
+			return nil, err
+		}
+		if _, exists := d.progs[v.N]; exists {
+			log.Printf("Warning: %s redefined", v.Galaxy())
+		}
+		d.progs[v.N] = p
+	}
+	return d, nil
+}
+
+func OpenDefinitions(fn string) (*Definitions, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewDefinitions(f)
+}
+
+// Vars returns the defined variables in order.
+func (d *Definitions) Vars() []int {
+	r := make([]int, 0, len(d.progs))
+	for n := range d.progs {
+		r = append(r, n)
+	}
+	d.syms.Sort(r)
+	return r
+}
+
+// WithDefinitions makes the context look up variables it does not define
+// itself in d.
+func WithDefinitions(d *Definitions) Option {
+	return func(c *Ctx) {
+		c.Defs = d
+	}
+}
+
+// NewSession starts a session on a new context of the definitions.
+func (d *Definitions) NewSession(serverURL *url.URL, opts ...Option) *Session {
+	opts = append([]Option{WithDefinitions(d)}, opts...)
+	return NewContextSession(NewContext(serverURL, opts...))
+}
+
+// bind builds the expression of shared definition n in the context.
+func (c *Ctx) bind(n int) (Token, bool) {
+	if c.Defs == nil {
+		return nil, false
+	}
+	prog, ok := c.Defs.progs[n]
+	if !ok {
+		return nil, false
+	}
+	p, err := Interpret(c, prog)
+	if err != nil {
+		panic(err)
+	}
+	if c.Lazy {
+		p = NewThunk(p)
+	}
+	c.Vars[n] = p
+	c.setSource(n, prog)
+	return p, true
+}
@@ -0,0 +1,125 @@
+package interpreter
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefinitions(t *testing.T) {
+	d, err := NewDefinitions(strings.NewReader("# countdown\n" + countdown + ":2 = ap :1 10\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, d.Vars())
+
+	for _, graph := range []bool{false, true} {
+		c := NewContext(nil, WithDefinitions(d), WithGraph(graph))
+		tok, err := TryParseString(c, "ap ap add 1 :2")
+		require.NoError(t, err)
+		assert.Equal(t, []Token{Int{V: 11}}, tok, "graph %v", graph)
+
+		// Definitions of the context hide the shared ones.
+		c.SetVar(2, Int{V: 5})
+		tok, err = TryParseString(c, "ap ap add 1 :2")
+		require.NoError(t, err)
+		assert.Equal(t, []Token{Int{V: 6}}, tok, "graph %v", graph)
+
+		_, err = TryParseString(c, "ap inc :3")
+		assert.Equal(t, &UnboundVariable{N: 3}, err)
+	}
+
+	_, err = NewDefinitions(strings.NewReader(":1 = 1\nap inc :1\n"))
+	var pe *ParseError
+	if assert.True(t, errors.As(err, &pe), "%v", err) {
+		assert.Equal(t, "ap inc :1", pe.Text)
+	}
+	_, err = NewDefinitions(strings.NewReader(":1 = ap inc\n"))
+	assert.Error(t, err)
+}
+
+func TestDefinition(t *testing.T) {
+	d, err := NewDefinitions(strings.NewReader(":1 = ap inc 1\n:2 = ap inc :1\n"))
+	require.NoError(t, err)
+	for _, lazy := range []bool{false, true} {
+		c := NewContext(nil, WithDefinitions(d), WithLazy(lazy))
+		_, err := TryParseString(c, ":3 = ap inc :2\nap inc :3")
+		require.NoError(t, err)
+		c.SetVar(4, Int{V: 4})
+
+		for n, want := range map[int]string{
+			1: "ap inc 1",
+			2: "ap inc :1",
+			3: "ap inc :2",
+			4: "4",
+		} {
+			tok, ok := c.Definition(n)
+			if assert.True(t, ok, "lazy %v, :%d", lazy, n) {
+				assert.Equal(t, want, tok.Galaxy(), "lazy %v, :%d", lazy, n)
+			}
+		}
+		_, ok := c.Definition(5)
+		assert.False(t, ok)
+		assert.Equal(t, []int{1, 2, 3, 4}, c.Defined(), "lazy %v", lazy)
+	}
+}
+
+func TestDefinitionsSymbols(t *testing.T) {
+	d, err := NewDefinitions(strings.NewReader("one = 1\ntwo = ap inc one\n"))
+	require.NoError(t, err)
+	one, ok := d.syms.Lookup("one")
+	require.True(t, ok)
+	two, ok := d.syms.Lookup("two")
+	require.True(t, ok)
+	assert.Equal(t, []int{one.N, two.N}, d.Vars())
+
+	c := NewContext(nil, WithDefinitions(d))
+	tok, err := TryParseString(c, "three = ap inc two\nthree")
+	require.NoError(t, err)
+	assert.Equal(t, []Token{Int{V: 3}}, tok)
+	three, ok := c.Symbols().Lookup("three")
+	require.True(t, ok)
+	assert.NotContains(t, []int{one.N, two.N}, three.N)
+	assert.Equal(t, two, c.Symbols().Var(two.N))
+
+	// Names of one context are not seen by the definitions or other contexts.
+	_, ok = d.syms.Lookup("three")
+	assert.False(t, ok)
+	other := NewContext(nil, WithDefinitions(d))
+	_, ok = other.Symbols().Lookup("three")
+	assert.False(t, ok)
+	tok, err = TryParseString(other, "ap inc two")
+	require.NoError(t, err)
+	assert.Equal(t, []Token{Int{V: 3}}, tok)
+}
+
+func TestDefinitionsConcurrent(t *testing.T) {
+	d, err := OpenDefinitions("../galaxy.txt")
+	require.NoError(t, err)
+	assert.Contains(t, d.Vars(), 1338)
+
+	var wg sync.WaitGroup
+	states := make([]string, 8)
+	errs := make([]error, len(states))
+	for i := range states {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := d.NewSession(nil, WithGraph(i%2 == 1))
+			for j := 0; j < 2; j++ {
+				if _, _, err := s.ClickCurrent(0, 0); err != nil {
+					errs[i] = err
+					return
+				}
+			}
+			states[i] = s.State.Galaxy()
+		}(i)
+	}
+	wg.Wait()
+	for i := range states {
+		require.NoError(t, errs[i])
+		assert.Equal(t, "ap ap cons 0 ap ap cons ap ap cons 1 nil ap ap cons 0 ap ap cons nil nil", states[i], "session %d", i)
+	}
+}
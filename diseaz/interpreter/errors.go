@@ -0,0 +1,213 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EvalError is implemented by all errors raised while parsing or evaluating
+// galaxy programs. Evaluation reports them by panicking; the Try* functions
+// recover them and return them as regular errors.
+type EvalError interface {
+	error
+	evalError()
+}
+
+type TypeMismatch struct {
+	Expected string
+	Got      Token
+}
+
+func (e *TypeMismatch) Error() string {
+	return fmt.Sprintf("Type mismatch: expected %s, got %s", e.Expected, e.Got)
+}
+
+func (e *TypeMismatch) evalError() {}
+
+type UnboundVariable struct {
+	N    int
+	Name string
+}
+
+func (e *UnboundVariable) Error() string {
+	return fmt.Sprintf("Variable does not exist: %s", VarN{N: e.N, Name: e.Name}.Galaxy())
+}
+
+func (e *UnboundVariable) evalError() {}
+
+type DivisionByZero struct {
+	X Int
+}
+
+func (e *DivisionByZero) Error() string {
+	return fmt.Sprintf("Division by zero: %s / 0", e.X.Galaxy())
+}
+
+func (e *DivisionByZero) evalError() {}
+
+type BadModulation struct {
+	Signal string
+	Reason string
+}
+
+func (e *BadModulation) Error() string {
+	return fmt.Sprintf("Bad modulation: %s: %#v", e.Reason, e.Signal)
+}
+
+func (e *BadModulation) evalError() {}
+
+// Unsupported is raised by tokens the interpreter does not implement.
+type Unsupported struct {
+	Token Token
+}
+
+func (e *Unsupported) Error() string {
+	return fmt.Sprintf("Not implemented: %s", e.Token)
+}
+
+func (e *Unsupported) evalError() {}
+
+type ParseError struct {
+	Text   string
+	Reason string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Parse error: %s: %#v: %s", e.Reason, e.Text, e.Err)
+	}
+	return fmt.Sprintf("Parse error: %s: %#v", e.Reason, e.Text)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ParseError) evalError() {}
+
+type SendError struct {
+	Message string
+	Err     error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("Unexpected server response to %#v:\n%s", e.Message, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SendError) evalError() {}
+
+// Aborted stops an evaluation that the user aborted in a Debugger.
+type Aborted struct {
+	Err error
+}
+
+func (e *Aborted) Error() string {
+	return fmt.Sprintf("Evaluation stopped: %s", e.Err)
+}
+
+func (e *Aborted) Unwrap() error {
+	return e.Err
+}
+
+func (e *Aborted) evalError() {}
+
+// catchEvalError stores a recovered EvalError into err. Any other panic is
+// propagated.
+func catchEvalError(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if e, ok := r.(EvalError); ok {
+		*err = e
+		return
+	}
+	panic(r)
+}
+
+func asInt(t Token) Int {
+	v, ok := t.(Int)
+	if !ok {
+		panic(&TypeMismatch{Expected: "int", Got: t})
+	}
+	return v
+}
+
+func asFunc(t Token) Func {
+	v, ok := t.(Func)
+	if !ok {
+		panic(&TypeMismatch{Expected: "function", Got: t})
+	}
+	return v
+}
+
+func asCons(t Token) ICons {
+	v, ok := t.(ICons)
+	if !ok {
+		panic(&TypeMismatch{Expected: "list", Got: t})
+	}
+	return v
+}
+
+func asSignal(t Token) Signal {
+	v, ok := t.(Signal)
+	if !ok {
+		panic(&TypeMismatch{Expected: "signal", Got: t})
+	}
+	return v
+}
+
+func evalInt(c Context, t Token) Int {
+	return asInt(c.Eval(t))
+}
+
+func evalFunc(c Context, t Token) Func {
+	return asFunc(c.Eval(t))
+}
+
+func evalCons(c Context, t Token) ICons {
+	return asCons(c.Eval(t))
+}
+
+// Limits of LimitError.
+const (
+	LimitSteps   = "steps"
+	LimitDepth   = "depth"
+	LimitContext = "context"
+)
+
+// LimitError stops an evaluation that exceeds a limit of its context.
+type LimitError struct {
+	// Limit is one of LimitSteps, LimitDepth and LimitContext.
+	Limit string
+	// Value is the exceeded number of steps or depth.
+	Value int
+	// Err is the error of the done context.
+	Err error
+	// Trace is the innermost part of the evaluation stack, outermost first.
+	Trace []string
+}
+
+func (e *LimitError) Error() string {
+	var b strings.Builder
+	if e.Limit == LimitContext {
+		fmt.Fprintf(&b, "Evaluation stopped: %s", e.Err)
+	} else {
+		fmt.Fprintf(&b, "Evaluation limit exceeded: %s %d", e.Limit, e.Value)
+	}
+	for _, t := range e.Trace {
+		fmt.Fprintf(&b, "\n  in %s", t)
+	}
+	return b.String()
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LimitError) evalError() {}
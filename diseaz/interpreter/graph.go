@@ -0,0 +1,778 @@
+package interpreter
+
+// Graph reduction evaluator.
+//
+// Tokens are compiled into a graph of mutable nodes that is reduced to weak
+// head normal form with an explicit spine stack instead of Go recursion. A
+// reduced redex is overwritten in place, so every reference to it shares the
+// result. Definitions are compiled once per context and reduced in place as
+// well.
+
+type nodeKind uint8
+
+const (
+	nodeAp nodeKind = iota
+	// nodeInd is an indirection to a.
+	nodeInd
+	// nodeVar is a reference to the definition number i.V, resolved into a
+	// on first use. The VarN is kept in tok.
+	nodeVar
+	nodePrim
+	nodeInt
+	// nodeToken is an opaque token, e.g. a picture or a signal.
+	nodeToken
+)
+
+type node struct {
+	kind nodeKind
+	op   op
+	// whnf marks an application that is a partial application already in
+	// weak head normal form.
+	whnf bool
+	f, a *node
+	i    Int
+	tok  Token
+}
+
+func (n *node) setInd(to *node) {
+	*n = node{kind: nodeInd, a: to}
+}
+
+func (n *node) setInt(v Int) {
+	*n = node{kind: nodeInt, i: v}
+}
+
+func apNode(f, a *node) *node {
+	return &node{kind: nodeAp, f: f, a: a}
+}
+
+type op uint8
+
+const (
+	opI op = iota
+	opT
+	opF
+	opS
+	opC
+	opB
+	opCons
+	opNil
+	opCar
+	opCdr
+	opIsNil
+	opInc
+	opDec
+	opNeg
+	opPwr2
+	opAdd
+	opMul
+	opDiv
+	opEq
+	opLt
+	opIf0
+	opInteract
+	opInteractHelper
+	opSend
+	opModulate
+	opDemodulate
+	opDraw
+	opMultipledraw
+	numOps
+)
+
+var opArity = [numOps]int{
+	opI: 1, opT: 2, opF: 2, opS: 3, opC: 3, opB: 3,
+	opCons: 3, opNil: 1, opCar: 1, opCdr: 1, opIsNil: 1,
+	opInc: 1, opDec: 1, opNeg: 1, opPwr2: 1,
+	opAdd: 2, opMul: 2, opDiv: 2, opEq: 2, opLt: 2,
+	opIf0: 3, opInteract: 3, opInteractHelper: 2,
+	opSend: 1, opModulate: 1, opDemodulate: 1, opDraw: 1, opMultipledraw: 1,
+}
+
+var opTokens = [numOps]Token{
+	opI: I{}, opT: True{}, opF: False{}, opS: S{}, opC: C{}, opB: B{},
+	opCons: Cons{}, opNil: Nil{}, opCar: Car{}, opCdr: Cdr{}, opIsNil: IsNil{},
+	opInc: Inc{}, opDec: Dec{}, opNeg: Neg{}, opPwr2: Pwr2{},
+	opAdd: Add{}, opMul: Mul{}, opDiv: Div{}, opEq: Eq{}, opLt: Lt{},
+	opIf0: If0{}, opInteract: Interact{}, opInteractHelper: interactHelper{},
+	opSend: Send{}, opModulate: Modulate{}, opDemodulate: Demodulate{}, opDraw: Draw{}, opMultipledraw: Multipledraw{},
+}
+
+// primOf returns the primitive of t and the arguments it is applied to.
+func primOf(t Token) (op, []Token, bool) {
+	switch t := t.(type) {
+	case I:
+		return opI, nil, true
+	case I1:
+		return opI, []Token{t.X0}, true
+	case True:
+		return opT, nil, true
+	case True1:
+		return opT, []Token{t.X0}, true
+	case True2:
+		return opT, []Token{t.X0, Nil{}}, true
+	case False:
+		return opF, nil, true
+	case False1:
+		return opF, []Token{Nil{}}, true
+	case False2:
+		return opF, []Token{Nil{}, t.X1}, true
+	case S:
+		return opS, nil, true
+	case S1:
+		return opS, []Token{t.X0}, true
+	case S2:
+		return opS, []Token{t.X0, t.X1}, true
+	case S3:
+		return opS, []Token{t.X0, t.X1, t.X2}, true
+	case C:
+		return opC, nil, true
+	case C1:
+		return opC, []Token{t.X0}, true
+	case C2:
+		return opC, []Token{t.X0, t.X1}, true
+	case C3:
+		return opC, []Token{t.X0, t.X1, t.X2}, true
+	case B:
+		return opB, nil, true
+	case B1:
+		return opB, []Token{t.X0}, true
+	case B2:
+		return opB, []Token{t.X0, t.X1}, true
+	case B3:
+		return opB, []Token{t.X0, t.X1, t.X2}, true
+	case Cons, Vec:
+		return opCons, nil, true
+	case Cons1:
+		return opCons, []Token{t.X0}, true
+	case Cons2:
+		return opCons, []Token{t.X0, t.X1}, true
+	case Cons3:
+		return opCons, []Token{t.X0, t.X1, t.X2}, true
+	case Nil:
+		return opNil, nil, true
+	case Nil1:
+		return opNil, []Token{Nil{}}, true
+	case isNil:
+		return opT, []Token{True1{X0: False{}}}, true
+	case isNil1:
+		return opT, []Token{False{}}, true
+	case isNil2:
+		return opF, nil, true
+	case Car:
+		return opCar, nil, true
+	case Car1:
+		return opCar, []Token{t.X0}, true
+	case Cdr:
+		return opCdr, nil, true
+	case Cdr1:
+		return opCdr, []Token{t.X0}, true
+	case IsNil:
+		return opIsNil, nil, true
+	case IsNil1:
+		return opIsNil, []Token{t.X0}, true
+	case Inc:
+		return opInc, nil, true
+	case Inc1:
+		return opInc, []Token{t.X0}, true
+	case Dec:
+		return opDec, nil, true
+	case Dec1:
+		return opDec, []Token{t.X0}, true
+	case Neg:
+		return opNeg, nil, true
+	case Neg1:
+		return opNeg, []Token{t.X0}, true
+	case Pwr2:
+		return opPwr2, nil, true
+	case Pwr21:
+		return opPwr2, []Token{t.X0}, true
+	case Add:
+		return opAdd, nil, true
+	case Add1:
+		return opAdd, []Token{t.X0}, true
+	case Add2:
+		return opAdd, []Token{t.X0, t.X1}, true
+	case Mul:
+		return opMul, nil, true
+	case Mul1:
+		return opMul, []Token{t.X0}, true
+	case Mul2:
+		return opMul, []Token{t.X0, t.X1}, true
+	case Div:
+		return opDiv, nil, true
+	case Div1:
+		return opDiv, []Token{t.X0}, true
+	case Div2:
+		return opDiv, []Token{t.X0, t.X1}, true
+	case Eq:
+		return opEq, nil, true
+	case Eq1:
+		return opEq, []Token{t.X0}, true
+	case Eq2:
+		return opEq, []Token{t.X0, t.X1}, true
+	case Lt:
+		return opLt, nil, true
+	case Lt1:
+		return opLt, []Token{t.X0}, true
+	case Lt2:
+		return opLt, []Token{t.X0, t.X1}, true
+	case If0:
+		return opIf0, nil, true
+	case If01:
+		return opIf0, []Token{t.X0}, true
+	case If02:
+		return opIf0, []Token{t.X0, t.X1}, true
+	case If03:
+		return opIf0, []Token{t.X0, t.X1, t.X2}, true
+	case Interact:
+		return opInteract, nil, true
+	case Interact1:
+		return opInteract, []Token{t.X0}, true
+	case Interact2:
+		return opInteract, []Token{t.X0, t.X1}, true
+	case Interact3:
+		return opInteract, []Token{t.X0, t.X1, t.X2}, true
+	case interactHelper:
+		return opInteractHelper, nil, true
+	case interactHelper1:
+		return opInteractHelper, []Token{t.X0}, true
+	case interactHelper2:
+		return opInteractHelper, []Token{t.X0, t.X1}, true
+	case Send:
+		return opSend, nil, true
+	case Send1:
+		return opSend, []Token{t.X0}, true
+	case Modulate:
+		return opModulate, nil, true
+	case Modulate1:
+		return opModulate, []Token{t.X0}, true
+	case Demodulate:
+		return opDemodulate, nil, true
+	case Demodulate1:
+		return opDemodulate, []Token{t.X0}, true
+	case Draw:
+		return opDraw, nil, true
+	case Draw1:
+		return opDraw, []Token{t.X0}, true
+	case Multipledraw:
+		return opMultipledraw, nil, true
+	case Multipledraw1:
+		return opMultipledraw, []Token{t.X0}, true
+	}
+	return 0, nil, false
+}
+
+// tokenCtx evaluates tokens with the recursive evaluator. The graph
+// evaluator hands primitives with side effects to their token
+// implementations with it.
+type tokenCtx struct {
+	*Ctx
+}
+
+func (c tokenCtx) Eval(t Token) Token {
+	c.CountEval()
+	for do := true; do; {
+		t, do = t.Eval(c)
+	}
+	return t
+}
+
+// graph is the graph reduction state of a context.
+type graph struct {
+	c     *Ctx
+	defs  map[int]*node
+	prims [numOps]*node
+}
+
+func newGraph(c *Ctx) *graph {
+	g := &graph{
+		c:    c,
+		defs: make(map[int]*node),
+	}
+	for o := range g.prims {
+		g.prims[o] = &node{kind: nodePrim, op: op(o)}
+	}
+	return g
+}
+
+func (g *graph) prim(o op) *node {
+	return g.prims[o]
+}
+
+// def returns the compiled definition of variable n.
+func (g *graph) def(n int) *node {
+	d, ok := g.defs[n]
+	if !ok {
+		d = g.compile(g.c.lookup(n))
+		g.defs[n] = d
+	}
+	return d
+}
+
+type compileItem struct {
+	n *node
+	t Token
+}
+
+// compile converts t into a graph. Shared thunks become shared nodes.
+func (g *graph) compile(t Token) *node {
+	root := &node{}
+	work := []compileItem{{n: root, t: t}}
+	thunks := make(map[*Thunk]*node)
+	for len(work) > 0 {
+		it := work[len(work)-1]
+		work = work[:len(work)-1]
+		n := it.n
+		switch t := it.t.(type) {
+		case *Thunk:
+			if m, ok := thunks[t]; ok {
+				n.setInd(m)
+				continue
+			}
+			thunks[t] = n
+			work = append(work, compileItem{n: n, t: t.T})
+		case VarN:
+			*n = node{kind: nodeVar, i: Int{V: int64(t.N)}, tok: t}
+		case Int:
+			n.setInt(t)
+		case Ap2:
+			*n = node{kind: nodeAp, f: &node{}, a: &node{}}
+			work = append(work, compileItem{n: n.f, t: t.F}, compileItem{n: n.a, t: t.A})
+		default:
+			o, args, ok := primOf(t)
+			if !ok {
+				*n = node{kind: nodeToken, tok: t}
+				continue
+			}
+			if len(args) == 0 {
+				n.setInd(g.prim(o))
+				continue
+			}
+			f := g.prim(o)
+			for _, arg := range args[:len(args)-1] {
+				f = apNode(f, &node{})
+				work = append(work, compileItem{n: f.a, t: arg})
+			}
+			*n = node{kind: nodeAp, f: f, a: &node{}}
+			work = append(work, compileItem{n: n.a, t: args[len(args)-1]})
+		}
+	}
+	return root
+}
+
+// deref follows indirections and variable references.
+func (g *graph) deref(n *node) *node {
+	for {
+		switch n.kind {
+		case nodeInd:
+			n = n.a
+		case nodeVar:
+			if n.a == nil {
+				n.a = g.def(int(n.i.V))
+				if g.c.Tracer != nil {
+					g.c.traceEvent(TraceVar, g.c.CallLevel, n.tok, g.c.Vars[int(n.i.V)])
+				}
+			}
+			n = n.a
+		default:
+			return n
+		}
+	}
+}
+
+// ready reports whether n is in weak head normal form.
+func (g *graph) ready(n *node) bool {
+	n = g.deref(n)
+	return n.kind != nodeAp || n.whnf
+}
+
+// intArg returns the value of the reduced argument n.
+func (g *graph) intArg(n *node) Int {
+	n = g.deref(n)
+	if n.kind != nodeInt {
+		panic(&TypeMismatch{Expected: "int", Got: g.show(n)})
+	}
+	return n.i
+}
+
+// pair returns the elements of n if it is a reduced cons pair.
+func (g *graph) pair(n *node) (x, y *node, ok bool) {
+	n = g.deref(n)
+	if n.kind != nodeAp || !n.whnf {
+		return nil, nil, false
+	}
+	f := g.deref(n.f)
+	if f.kind != nodeAp || g.deref(f.f) != g.prim(opCons) {
+		return nil, nil, false
+	}
+	return f.a, n.a, true
+}
+
+// strictArgs returns the number of leading arguments of o that have to be
+// reduced before o is applied.
+func strictArgs(o op) int {
+	switch o {
+	case opInc, opDec, opNeg, opPwr2, opIf0:
+		return 1
+	case opAdd, opMul, opDiv, opEq, opLt:
+		return 2
+	}
+	return 0
+}
+
+// pending returns the first strict argument of o on the spine that is not
+// reduced yet, or nil.
+func (g *graph) pending(o op, spine []*node) *node {
+	for i := 0; i < strictArgs(o); i++ {
+		if x := spine[len(spine)-1-i].a; !g.ready(x) {
+			return x
+		}
+	}
+	return nil
+}
+
+// whnf reduces n to weak head normal form and returns the reduced node.
+// Arguments of strict primitives are reduced on the same stack: the spine of
+// the suspended redex stays below the new base until the argument is done.
+func (g *graph) whnf(n *node) *node {
+	stack := make([]*node, 0, 16)
+	var bases []int
+	base := 0
+	for {
+		n = g.deref(n)
+		if n.kind == nodeAp {
+			stack = append(stack, n)
+			n = n.f
+			continue
+		}
+
+		args := len(stack) - base
+		if n.kind == nodePrim && args >= opArity[n.op] {
+			if x := g.pending(n.op, stack); x != nil {
+				bases = append(bases, base)
+				base = len(stack)
+				if max := g.c.MaxDepth; max > 0 && len(bases) > max {
+					panic(&LimitError{Limit: LimitDepth, Value: max, Trace: g.trace(stack, bases, base)})
+				}
+				n = x
+				continue
+			}
+			k := opArity[n.op]
+			root := stack[len(stack)-k]
+			g.step(stack, bases, base)
+			if g.c.Tracer != nil {
+				redex := g.show(root)
+				g.rewrite(n.op, stack[len(stack)-k:])
+				g.c.traceEvent(TraceApply, g.c.CallLevel+len(bases), redex, g.show(root))
+			} else {
+				g.rewrite(n.op, stack[len(stack)-k:])
+			}
+			stack = stack[:len(stack)-k]
+			n = root
+			continue
+		}
+
+		if args > 0 && n.kind != nodePrim {
+			f, ok := n.tok.(Func)
+			if n.kind != nodeToken || !ok {
+				panic(&TypeMismatch{Expected: "function", Got: g.show(n)})
+			}
+			top := stack[len(stack)-1]
+			g.step(stack, bases, base)
+			top.setInd(g.compile(f.Apply(g.quote(top.a))))
+			stack = stack[:len(stack)-1]
+			n = top
+			continue
+		}
+
+		// n with the arguments on the stack is a value.
+		if args > 0 {
+			for _, s := range stack[base:] {
+				s.whnf = true
+			}
+			n = stack[base]
+			stack = stack[:base]
+		}
+		if len(bases) == 0 {
+			return n
+		}
+		base = bases[len(bases)-1]
+		bases = bases[:len(bases)-1]
+		n = stack[len(stack)-1].f
+	}
+}
+
+// step counts a reduction step like Ctx.CountEval.
+func (g *graph) step(stack []*node, bases []int, base int) {
+	if e := g.c.step(); e != nil {
+		e.Trace = g.trace(stack, bases, base)
+		panic(e)
+	}
+}
+
+// trace returns the outermost redex of every frame of the stack, i.e. the
+// expressions whose arguments are being reduced.
+func (g *graph) trace(stack []*node, bases []int, base int) []string {
+	var ts []Token
+	for _, b := range append(bases[:len(bases):len(bases)], base) {
+		if b < len(stack) {
+			ts = append(ts, g.show(stack[b]))
+		}
+	}
+	return traceOf(ts)
+}
+
+// rewrite overwrites spine[0], the application of o to the arguments on the
+// spine, with the result.
+func (g *graph) rewrite(o op, spine []*node) {
+	root := spine[0]
+	arg := func(i int) *node {
+		return spine[len(spine)-1-i].a
+	}
+	switch o {
+	case opI:
+		root.setInd(arg(0))
+	case opT:
+		root.setInd(arg(0))
+	case opF:
+		root.setInd(arg(1))
+	case opS:
+		x, y, z := arg(0), arg(1), arg(2)
+		*root = node{kind: nodeAp, f: apNode(x, z), a: apNode(y, z)}
+	case opC:
+		x, y, z := arg(0), arg(1), arg(2)
+		*root = node{kind: nodeAp, f: apNode(x, z), a: y}
+	case opB:
+		x, y, z := arg(0), arg(1), arg(2)
+		*root = node{kind: nodeAp, f: x, a: apNode(y, z)}
+	case opCons:
+		x, y, z := arg(0), arg(1), arg(2)
+		*root = node{kind: nodeAp, f: apNode(z, x), a: y}
+	case opNil:
+		root.setInd(g.prim(opT))
+	case opCar, opCdr:
+		x := arg(0)
+		if a, b, ok := g.pair(x); ok {
+			if o == opCar {
+				root.setInd(a)
+			} else {
+				root.setInd(b)
+			}
+			return
+		}
+		sel := g.prim(opT)
+		if o == opCdr {
+			sel = g.prim(opF)
+		}
+		*root = node{kind: nodeAp, f: x, a: sel}
+	case opIsNil:
+		isNil := apNode(g.prim(opT), apNode(g.prim(opT), g.prim(opF)))
+		*root = node{kind: nodeAp, f: arg(0), a: isNil}
+	case opInc:
+		root.setInt(addInts(g.intArg(arg(0)), Int{V: 1}))
+	case opDec:
+		root.setInt(addInts(g.intArg(arg(0)), Int{V: -1}))
+	case opNeg:
+		root.setInt(negInt(g.intArg(arg(0))))
+	case opPwr2:
+		root.setInt(pwr2Int(g.intArg(arg(0))))
+	case opAdd:
+		root.setInt(addInts(g.intArg(arg(0)), g.intArg(arg(1))))
+	case opMul:
+		root.setInt(mulInts(g.intArg(arg(0)), g.intArg(arg(1))))
+	case opDiv:
+		root.setInt(divInts(g.intArg(arg(0)), g.intArg(arg(1))))
+	case opEq, opLt:
+		c := g.intArg(arg(0)).Cmp(g.intArg(arg(1)))
+		if c == 0 && o == opEq || c < 0 && o == opLt {
+			root.setInd(g.prim(opT))
+		} else {
+			root.setInd(g.prim(opF))
+		}
+	case opIf0:
+		if g.intArg(arg(0)).Sign() == 0 {
+			root.setInd(arg(1))
+		} else {
+			root.setInd(arg(2))
+		}
+	case opInteract:
+		// (interact x0 x1 x2) = (f38 x0 (x0 x1 x2))
+		x0, x1, x2 := arg(0), arg(1), arg(2)
+		*root = node{kind: nodeAp, f: apNode(g.prim(opInteractHelper), x0), a: apNode(apNode(x0, x1), x2)}
+	case opInteractHelper:
+		// (f38 x0 (flag, newState, data)) = if0 flag
+		//   (cons newState (cons (multipledraw data) nil))
+		//   (interact x0 newState (send data))
+		x0, x1 := arg(0), arg(1)
+		car := func(n *node) *node { return apNode(g.prim(opCar), n) }
+		cdr := func(n *node) *node { return apNode(g.prim(opCdr), n) }
+		rest := cdr(x1)
+		newState := car(rest)
+		data := car(cdr(rest))
+		then := apNode(apNode(g.prim(opCons), newState),
+			apNode(apNode(g.prim(opCons), apNode(g.prim(opMultipledraw), data)), g.prim(opNil)))
+		els := apNode(apNode(apNode(g.prim(opInteract), x0), newState), apNode(g.prim(opSend), data))
+		*root = node{kind: nodeAp, f: apNode(apNode(g.prim(opIf0), car(x1)), then), a: els}
+	default:
+		// Primitives with side effects take fully evaluated arguments and
+		// are left to their token implementations.
+		f := opTokens[o].(Func).Apply(g.value(arg(0)))
+		root.setInd(g.compile(tokenCtx{g.c}.Eval(f)))
+	}
+}
+
+// value reduces n the way Ctx.Eval does: lists are reduced element by
+// element, other values are converted back into tokens as they are. Nodes
+// shared in the graph stay shared in the result. A list that contains itself
+// refers to itself by the variable that closes the cycle instead of being
+// unrolled.
+func (g *graph) value(n *node) Token {
+	done := make(map[*node]Token)
+	visiting := make(map[*node]bool)
+	stack := []*node{n}
+	for len(stack) > 0 {
+		g.charge(stack, len(visiting))
+		top := g.whnf(stack[len(stack)-1])
+		if _, ok := done[top]; ok {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		x, y, ok := g.pair(top)
+		if !ok {
+			done[top] = g.quote(top)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		visiting[top] = true
+		xv, xok := g.element(x, done, visiting)
+		yv, yok := g.element(y, done, visiting)
+		if xok && yok {
+			done[top] = Cons2{X0: xv, X1: yv}
+			delete(visiting, top)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if !yok {
+			stack = append(stack, y)
+		}
+		if !xok {
+			stack = append(stack, x)
+		}
+	}
+	return done[g.whnf(n)]
+}
+
+// element returns the value of the list element n if it is known: either
+// reduced already or a list that is being reduced, i.e. a cycle.
+func (g *graph) element(n *node, done map[*node]Token, visiting map[*node]bool) (Token, bool) {
+	r := g.whnf(n)
+	if t, ok := done[r]; ok {
+		return t, true
+	}
+	if visiting[r] {
+		return g.quote(n), true
+	}
+	return nil, false
+}
+
+// charge counts a step of value or quote, which convert nodes into tokens
+// without reducing them, and checks the list depth of value.
+func (g *graph) charge(stack []*node, depth int) {
+	if e := g.c.step(); e != nil {
+		e.Trace = g.valueTrace(stack)
+		panic(e)
+	}
+	if max := g.c.MaxDepth; max > 0 && depth > max {
+		panic(&LimitError{Limit: LimitDepth, Value: max, Trace: g.valueTrace(stack)})
+	}
+}
+
+// valueTrace returns the innermost nodes being converted.
+func (g *graph) valueTrace(stack []*node) []string {
+	if len(stack) > traceLen {
+		stack = stack[len(stack)-traceLen:]
+	}
+	ts := make([]Token, len(stack))
+	for i, n := range stack {
+		ts[i] = g.show(n)
+	}
+	return traceOf(ts)
+}
+
+// quote converts n into a token without reducing it.
+func (g *graph) quote(n *node) Token {
+	return g.quoteNodes(n, true)
+}
+
+// show is quote for traces and errors. It is not charged, as they are built
+// when a limit is exceeded already.
+func (g *graph) show(n *node) Token {
+	return g.quoteNodes(n, false)
+}
+
+func (g *graph) quoteNodes(n *node, charge bool) Token {
+	done := make(map[*node]Token)
+	stack := []*node{n}
+	for len(stack) > 0 {
+		if charge {
+			g.charge(stack, 0)
+		}
+		top := stack[len(stack)-1]
+		if _, ok := done[top]; ok {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		switch top.kind {
+		case nodeInd:
+			t, ok := done[top.a]
+			if !ok {
+				stack = append(stack, top.a)
+				continue
+			}
+			done[top] = t
+		case nodeVar:
+			done[top] = top.tok
+		case nodePrim:
+			done[top] = opTokens[top.op]
+		case nodeInt:
+			done[top] = top.i
+		case nodeToken:
+			done[top] = top.tok
+		case nodeAp:
+			f, fok := done[top.f]
+			a, aok := done[top.a]
+			if !fok || !aok {
+				if !aok {
+					stack = append(stack, top.a)
+				}
+				if !fok {
+					stack = append(stack, top.f)
+				}
+				continue
+			}
+			done[top] = applyToken(f, a)
+		}
+		stack = stack[:len(stack)-1]
+	}
+	return done[n]
+}
+
+// applyToken builds the application of f to a the way Interpret does.
+func applyToken(f, a Token) Token {
+	if ff, ok := f.(Func); ok {
+		if _, ok := f.(Checkerboard); !ok {
+			return ff.Apply(a)
+		}
+	}
+	return Ap2{F: f, A: a}
+}
+
+// EvalGraph evaluates t with the graph reduction evaluator.
+func (c *Ctx) EvalGraph(t Token) Token {
+	if c.graph == nil {
+		c.graph = newGraph(c)
+	}
+	g := c.graph
+	return g.value(g.compile(t))
+}
@@ -0,0 +1,141 @@
+package interpreter
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// graphEagerTests are programs for TestGraphEager. Each one evaluates to a
+// single token or fails.
+var graphEagerTests = []string{
+	"ap ap add 1 41",
+	"ap ap mul 6 7",
+	"ap ap div 43 -7",
+	"ap ap t 42 43",
+	"ap ap f 43 42",
+	"ap ap eq ap ap add 1 2 3",
+	":42 = ap ap s ap ap c ap eq 0 1 ap ap b ap mul 2 ap ap b :42 ap add -1\nap :42 4",
+	"ap ap i ap add 1 41",
+	"ap car ap ap cons 42 41",
+	"ap cdr ap ap cons 41 42",
+	"ap isnil nil",
+	"ap isnil ap ap cons 41 42",
+	"ap draw (ap ap vec 1 2, ap ap vec 41 42)",
+	"ap multipledraw ((ap ap vec 1 2, ap ap vec 41 42), (ap ap vec 3 4))",
+	"ap ap ap if0 ap dec 1 ap ap add 1 41 0",
+	"ap mod ap ap cons 1 ap ap cons 2 nil",
+	"()",
+	"(1, (2, 3), ap inc 3)",
+	"ap add 1",
+	"ap pwr2 70",
+	"ap ap mul 560803991675135 560803991675135",
+	"ap ap lt 9223372036854775807 9223372036854775808",
+	"ap inc :7",
+	"ap inc nil",
+	"ap ap div 1 0",
+	"ap ap cosn 1 nil",
+}
+
+// TestGraphEager checks that the graph reduction evaluator computes what the
+// recursive eager evaluator does.
+func TestGraphEager(t *testing.T) {
+	run := func(c *Ctx, text string) (string, error) {
+		tok, err := TryParseString(c, text)
+		if err != nil {
+			return "", err
+		}
+		require.Len(t, tok, 1, text)
+		return tok[0].Galaxy(), nil
+	}
+	for _, text := range graphEagerTests {
+		eager, eagerErr := run(NewContext(nil), text)
+		graph, graphErr := run(NewContext(nil, WithGraph(true)), text)
+		assert.Equal(t, eager, graph, text)
+		assert.IsType(t, eagerErr, graphErr, text)
+	}
+}
+
+func TestGraphSharing(t *testing.T) {
+	text := `:42 = ap ap s ap ap c ap eq 0 1 ap ap b ap mul 2 ap ap b :42 ap add -1
+:43 = ap :42 10
+ap ap add :43 :43`
+
+	lazy := NewContext(nil)
+	tok := ParseString(lazy, text)
+	require.Len(t, tok, 1)
+
+	graph := NewContext(nil, WithGraph(true))
+	tok = ParseString(graph, text)
+	require.Len(t, tok, 1)
+	assert.Equal(t, Int{V: 2048}, tok[0])
+
+	assert.LessOrEqual(t, graph.EvalCount, lazy.EvalCount)
+}
+
+func TestGraphDeep(t *testing.T) {
+	// :1 n = if n == 0 then 0 else 1 + :1(n - 1) nests a million additions.
+	c := NewContext(nil, WithGraph(true))
+	tok := ParseString(c, `:1 = ap ap s ap ap c ap eq 0 0 ap ap b ap add 1 ap ap b :1 ap add -1
+ap :1 1000000`)
+	require.Len(t, tok, 1)
+	assert.Equal(t, Int{V: 1000000}, tok[0])
+}
+
+func TestGraphList(t *testing.T) {
+	c := NewContext(nil, WithGraph(true))
+	tok := ParseString(c, `:1 = ap ap s ap ap c ap eq 0 nil ap ap s cons ap ap b :1 ap add -1
+ap :1 3
+ap add 1`)
+	require.Len(t, tok, 2)
+	assert.Equal(t, List(Int{V: 3}, Int{V: 2}, Int{V: 1}), tok[0])
+	assert.Equal(t, Add1{X0: Int{V: 1}}, tok[1])
+}
+
+func TestGraphCycle(t *testing.T) {
+	c := NewContext(nil, WithGraph(true))
+	tok, err := TryParseString(c, `ones = ap ap cons 1 ones
+ones
+ap car ap cdr ap cdr ones
+:1 = ap ap cons ap inc 1 ap ap cons 3 :1
+:1`)
+	require.NoError(t, err)
+	require.Len(t, tok, 3)
+	ones, ok := c.Symbols().Lookup("ones")
+	require.True(t, ok)
+	assert.Equal(t, Cons2{X0: Int{V: 1}, X1: ones}, tok[0])
+	assert.Equal(t, Int{V: 1}, tok[1])
+	assert.Equal(t, "ap ap cons 2 ap ap cons 3 :1", tok[2].Galaxy())
+}
+
+func TestGraphGalaxy(t *testing.T) {
+	galaxy, err := ioutil.ReadFile("../galaxy.txt")
+	require.NoError(t, err)
+	click := "ap ap ap interact :1338 ap ap cons 2 ap ap cons ap ap cons 4 ap ap cons 5 nil ap ap cons 0 ap ap cons nil nil ap ap vec 0 0"
+
+	var results []string
+	for _, graph := range []bool{false, true} {
+		c := NewContext(nil, WithGraph(graph))
+		ParseString(c, string(galaxy))
+		tok := ParseString(c, click)
+		require.Len(t, tok, 1)
+		results = append(results, tok[0].Galaxy())
+	}
+	assert.Equal(t, results[0], results[1])
+	assert.True(t, strings.HasPrefix(results[1], "ap ap cons "), results[1])
+}
+
+func BenchmarkInteractGalaxyGraph(b *testing.B) {
+	galaxy, err := ioutil.ReadFile("../galaxy.txt")
+	require.NoError(b, err)
+	c := NewContext(nil, WithGraph(true))
+	ParseString(c, string(galaxy))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParseString(c, "ap car ap ap ap interact :1338 ap ap cons 2 ap ap cons ap ap cons 4 ap ap cons 5 nil ap ap cons 0 ap ap cons nil nil ap ap vec 0 0")
+	}
+}
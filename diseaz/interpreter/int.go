@@ -0,0 +1,108 @@
+package interpreter
+
+import (
+	"math"
+	"math/big"
+)
+
+// NewBigInt returns b as Int. Values that fit into int64 are stored in V,
+// so small numbers always compare equal to Int{V: ...} literals.
+func NewBigInt(b *big.Int) Int {
+	if b.IsInt64() {
+		return Int{V: b.Int64()}
+	}
+	return Int{B: b}
+}
+
+func (t Int) IsBig() bool {
+	return t.B != nil
+}
+
+// Big returns the value as a new big.Int.
+func (t Int) Big() *big.Int {
+	if t.B != nil {
+		return new(big.Int).Set(t.B)
+	}
+	return big.NewInt(t.V)
+}
+
+func (t Int) Sign() int {
+	if t.B != nil {
+		return t.B.Sign()
+	}
+	switch {
+	case t.V < 0:
+		return -1
+	case t.V > 0:
+		return 1
+	}
+	return 0
+}
+
+func (t Int) Cmp(u Int) int {
+	if t.B == nil && u.B == nil {
+		switch {
+		case t.V < u.V:
+			return -1
+		case t.V > u.V:
+			return 1
+		}
+		return 0
+	}
+	return t.Big().Cmp(u.Big())
+}
+
+func addInts(x, y Int) Int {
+	if x.B == nil && y.B == nil {
+		r := x.V + y.V
+		if (r > x.V) == (y.V > 0) {
+			return Int{V: r}
+		}
+	}
+	return NewBigInt(new(big.Int).Add(x.Big(), y.Big()))
+}
+
+func mulInts(x, y Int) Int {
+	if x.B == nil && y.B == nil {
+		if x.V == 0 || y.V == 0 {
+			return Int{V: 0}
+		}
+		r := x.V * y.V
+		if r/y.V == x.V && !(x.V == -1 && y.V == math.MinInt64) && !(y.V == -1 && x.V == math.MinInt64) {
+			return Int{V: r}
+		}
+	}
+	return NewBigInt(new(big.Int).Mul(x.Big(), y.Big()))
+}
+
+// divInts truncates towards zero like Go integer division. It panics with
+// DivisionByZero if y is zero.
+func divInts(x, y Int) Int {
+	if y.Sign() == 0 {
+		panic(&DivisionByZero{X: x})
+	}
+	if x.B == nil && y.B == nil && !(x.V == math.MinInt64 && y.V == -1) {
+		return Int{V: x.V / y.V}
+	}
+	return NewBigInt(new(big.Int).Quo(x.Big(), y.Big()))
+}
+
+func negInt(x Int) Int {
+	if x.B == nil && x.V != math.MinInt64 {
+		return Int{V: -x.V}
+	}
+	return NewBigInt(new(big.Int).Neg(x.Big()))
+}
+
+func pwr2Int(x Int) Int {
+	if x.Sign() < 0 {
+		panic(&TypeMismatch{Expected: "non-negative int", Got: x})
+	}
+	if x.B == nil && x.V < 63 {
+		return Int{V: 1 << x.V}
+	}
+	if x.B != nil || x.V > math.MaxInt32 {
+		panic(&TypeMismatch{Expected: "reasonable power of 2", Got: x})
+	}
+	return NewBigInt(new(big.Int).Lsh(big.NewInt(1), uint(x.V)))
+}
@@ -11,13 +11,16 @@ func Interpret(c Context, p Program) (Token, error) {
 		// log.Printf("Token: %s", t)
 		switch t.(type) {
 		case Ap:
-			ft := pout.Pop()
-			at := pout.Pop()
+			ft, fok := pout.Pops()
+			at, aok := pout.Pops()
+			if !fok || !aok {
+				return nil, &ParseError{Text: p.String(), Reason: "not enough arguments for ap"}
+			}
 			ff, ok := ft.(Func)
 			if ok {
 				pout.Push(ff.Apply(at))
 			} else {
-				pout.Push(Ap2{F: ft, A: at})
+				pout.Push(c.Ap(ft, at))
 			}
 		default:
 			pout.Push(t)
@@ -25,34 +28,48 @@ func Interpret(c Context, p Program) (Token, error) {
 		// log.Printf("Out: %s", pout)
 	}
 	if len(pout) != 1 {
-		return nil, fmt.Errorf("Invalid compilation result: %s", pout)
+		return nil, &ParseError{Text: p.String(), Reason: fmt.Sprintf("invalid compilation result %s", pout)}
 	}
 	return pout[0], nil
 }
 
 func (c *Ctx) Eval(t Token) Token {
-	r, _ := c.EvalDo(t)
+	c.enter(t)
+	defer c.leave()
+	if c.Tracer != nil {
+		c.traceEvent(TraceEval, c.CallLevel, t, nil)
+	}
+	var r Token
+	if c.Graph && c.Lazy {
+		r = c.EvalGraph(t)
+	} else {
+		r, _ = c.EvalDo(t)
+	}
+	if c.Tracer != nil {
+		c.traceEvent(TraceResult, c.CallLevel, t, r)
+	}
 	return r
 }
 
+// TryEval is Eval that returns evaluation errors instead of panicking.
+func (c *Ctx) TryEval(t Token) (r Token, err error) {
+	defer catchEvalError(&err)
+	return c.Eval(t), nil
+}
+
 func (c *Ctx) EvalDo(t Token) (Token, bool) {
 	c.CountEval()
-	// lvl := c.Enter()
-	// defer c.Leave()
-	// indent := strings.Repeat("  ", lvl)
-
 	did := false
 	do := true
 	for do {
 		u := t
-		// log.Printf("%3d> %s%s", lvl, indent, u)
 		t, do = u.Eval(c)
-		// m := "=="
 		if do {
 			did = true
-			// m = "=>"
+			if c.Tracer != nil {
+				c.traceEvent(TraceApply, c.CallLevel, u, t)
+			}
 		}
-		// log.Printf("%3d< %s%s %s", lvl, indent, m, t)
 	}
 	return t, did
 }
@@ -1,7 +1,13 @@
 package interpreter
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"math/big"
+	"os"
 	"strings"
 	"testing"
 
@@ -228,3 +234,306 @@ func TestBraces(t *testing.T) {
 	tok = ProcessTokens(c, strings.Fields("(0, (1, 2), (3, 4))"))
 	log.Printf("tok: %s", tok.Galaxy())
 }
+
+func TestLazySharing(t *testing.T) {
+	text := `:42 = ap ap s ap ap c ap eq 0 1 ap ap b ap mul 2 ap ap b :42 ap add -1
+:43 = ap :42 10
+ap ap add :43 :43`
+
+	strict := NewContext(nil)
+	tok := ParseString(strict, text)
+	require.Len(t, tok, 1)
+	assert.Equal(t, Int{V: 2048}, tok[0])
+
+	lazy := NewContext(nil)
+	lazy.Lazy = true
+	tok = ParseString(lazy, text)
+	require.Len(t, tok, 1)
+	assert.Equal(t, Int{V: 2048}, tok[0])
+
+	assert.Less(t, lazy.EvalCount, strict.EvalCount)
+}
+
+// BenchmarkInteractGalaxy clicks in a warm context, where lazy evaluation
+// reuses the definitions reduced by the previous clicks.
+func BenchmarkInteractGalaxy(b *testing.B) {
+	galaxy, err := ioutil.ReadFile("../galaxy.txt")
+	require.NoError(b, err)
+	c := NewContext(nil)
+	c.Lazy = true
+	ParseString(c, string(galaxy))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParseString(c, "ap car ap ap ap interact :1338 ap ap cons 2 ap ap cons ap ap cons 4 ap ap cons 5 nil ap ap cons 0 ap ap cons nil nil ap ap vec 0 0")
+	}
+}
+
+// BenchmarkInteractGalaxyCold clicks once in a new context, lazy and eager
+// evaluation take about as long there.
+func BenchmarkInteractGalaxyCold(b *testing.B) {
+	galaxy, err := ioutil.ReadFile("../galaxy.txt")
+	require.NoError(b, err)
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("Lazy=%v", lazy), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				c := NewContext(nil)
+				c.Lazy = lazy
+				ParseString(c, string(galaxy))
+				b.StartTimer()
+				ParseString(c, "ap car ap ap ap interact :1338 ap ap cons 2 ap ap cons ap ap cons 4 ap ap cons 5 nil ap ap cons 0 ap ap cons nil nil ap ap vec 0 0")
+			}
+		})
+	}
+}
+
+func TestNamedVars(t *testing.T) {
+	c := NewContext(nil)
+	text := `pow2 = ap ap s ap ap c ap eq 0 1 ap ap b ap mul 2 ap ap b pow2 ap add -1
+:2 = ap pow2 3
+ap ap add :2 ap pow2 4`
+	tok := ParseReader(c, strings.NewReader(text))
+	require.Equal(t, []Token{Int{V: 24}}, tok)
+
+	syms := c.Symbols()
+	v, ok := syms.Lookup("pow2")
+	require.True(t, ok)
+	assert.Equal(t, v, ParseVarN(syms, "pow2"))
+	assert.Equal(t, v, syms.Var(v.N))
+	assert.Less(t, v.N, 0)
+	assert.Equal(t, "pow2", v.Galaxy())
+	assert.Equal(t, ":2", VarN{N: 2}.Galaxy())
+	assert.Nil(t, ParseVarN(syms, "cons"))
+	assert.Nil(t, ParseVarN(syms, "1x"))
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+	ParseString(c, "pow2 = 1")
+	assert.Contains(t, out.String(), "Warning: pow2 redefined")
+
+	// Names are known once defined: a misspelled built-in is an unknown
+	// token, not a variable.
+	_, err := TryParseString(c, "ap inc foo")
+	assert.EqualError(t, err, `Parse error: unknown token: "foo"`)
+	tok = ParseString(c, "early = ap inc later\nlater = 1\nearly")
+	assert.Equal(t, []Token{Int{V: 2}}, tok, "names are known in the whole text")
+
+	a, b := syms.Symbol("a_var"), syms.Symbol("b_var")
+	ns := []int{b.N, 5, a.N, 1}
+	syms.Sort(ns)
+	assert.Equal(t, []int{1, 5, a.N, b.N}, ns)
+
+	// Every context numbers its own names.
+	other := NewContext(nil)
+	_, ok = other.Symbols().Lookup("pow2")
+	assert.False(t, ok)
+	assert.Equal(t, v.N, other.Symbols().Symbol("other").N)
+}
+
+func TestShadowBuiltin(t *testing.T) {
+	// pwr2.txt defines pwr2 in galaxy syntax.
+	const pwr2 = "pwr2 = ap ap s ap ap c ap eq 0 1 ap ap b ap mul 2 ap ap b pwr2 ap add -1\n"
+	for _, graph := range []bool{false, true} {
+		var out bytes.Buffer
+		log.SetOutput(&out)
+		c := NewContext(nil, WithGraph(graph))
+		tok, err := TryParseString(c, pwr2+"ap pwr2 10")
+		log.SetOutput(os.Stderr)
+		require.NoError(t, err, "graph %v", graph)
+		assert.Equal(t, []Token{Int{V: 1024}}, tok, "graph %v", graph)
+		assert.Contains(t, out.String(), "Warning: pwr2 shadows a built-in token", "graph %v", graph)
+		v, ok := c.Symbols().Lookup("pwr2")
+		require.True(t, ok, "graph %v", graph)
+		def, ok := c.Definition(v.N)
+		require.True(t, ok, "graph %v", graph)
+		assert.Equal(t, "ap ap s ap ap c ap eq 0 1 ap ap b ap mul 2 ap ap b pwr2 ap add -1", def.Galaxy(), "graph %v", graph)
+
+		tok, err = TryParseString(c, "inc = ap add 2\nap inc 1")
+		require.NoError(t, err, "graph %v", graph)
+		assert.Equal(t, []Token{Int{V: 3}}, tok, "graph %v", graph)
+
+		// Other contexts still see the built-in tokens.
+		tok, err = TryParseString(NewContext(nil), "ap inc 1")
+		require.NoError(t, err, "graph %v", graph)
+		assert.Equal(t, []Token{Int{V: 2}}, tok, "graph %v", graph)
+	}
+}
+
+func TestUnboundVariable(t *testing.T) {
+	c := NewContext(nil)
+	_, err := TryParseString(c, "ap inc :7")
+	var e *UnboundVariable
+	require.True(t, errors.As(err, &e), "err: %v", err)
+	assert.Equal(t, 7, e.N)
+}
+
+func TestTypeMismatch(t *testing.T) {
+	c := NewContext(nil)
+	_, err := TryParseString(c, "ap inc nil")
+	var e *TypeMismatch
+	require.True(t, errors.As(err, &e), "err: %v", err)
+	assert.Equal(t, "int", e.Expected)
+}
+
+func TestUnsupported(t *testing.T) {
+	c := NewContext(nil)
+	_, err := c.TryEval(c.Ap(Checkerboard{}, Int{V: 1}))
+	var e *Unsupported
+	require.True(t, errors.As(err, &e), "err: %v", err)
+	assert.Equal(t, Checkerboard{}, e.Token)
+
+	err = func() (err error) {
+		defer catchEvalError(&err)
+		var p Program
+		p.Pop()
+		return nil
+	}()
+	var pe *ParseError
+	assert.True(t, errors.As(err, &pe), "err: %v", err)
+}
+
+func TestDivisionByZero(t *testing.T) {
+	for _, graph := range []bool{false, true} {
+		c := NewContext(nil, WithGraph(graph))
+		for _, s := range []string{"ap ap div 1 0", "ap ap div 100000000000000000000 0"} {
+			_, err := TryParseString(c, s)
+			var e *DivisionByZero
+			assert.True(t, errors.As(err, &e), "graph %v, %s: %v", graph, s, err)
+		}
+	}
+}
+
+func TestBadModulation(t *testing.T) {
+	c := NewContext(nil)
+	for _, s := range []string{"", "1", "0111", "00101", "0000"} {
+		_, err := c.TryEval(Demodulate1{X0: Signal{S: s}})
+		var e *BadModulation
+		assert.True(t, errors.As(err, &e), "signal %#v, err: %v", s, err)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	c := NewContext(nil)
+	for _, s := range []string{"ap inc f.o", "ap ap add 1", ":x = 1", ":-1 = 1", "ap = 1", "ap ap cosn 1 nil", "ap inc foo"} {
+		_, err := TryParseString(c, s)
+		var e *ParseError
+		assert.True(t, errors.As(err, &e), "text %#v, err: %v", s, err)
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	c := NewContext(nil)
+	tok := ParseString(c, `ap ap mul 560803991675135 560803991675135
+ap pwr2 70
+ap ap add 9223372036854775807 1
+ap ap div ap pwr2 70 ap pwr2 68
+ap neg -9223372036854775808
+ap ap lt 9223372036854775807 9223372036854775808`)
+	require.Len(t, tok, 6)
+	assert.Equal(t, "314501117078764886383377268225", tok[0].String())
+	assert.Equal(t, "1180591620717411303424", tok[1].String())
+	assert.Equal(t, "9223372036854775808", tok[2].String())
+	assert.Equal(t, Int{V: 4}, tok[3])
+	assert.Equal(t, "9223372036854775808", tok[4].String())
+	assert.Equal(t, True{}, tok[5])
+}
+
+func TestModulateBigInt(t *testing.T) {
+	c := NewContext(nil)
+	for _, s := range []string{"1180591620717411303424", "-9223372036854775808", "-1180591620717411303425"} {
+		tok := ParseString(c, s)
+		require.Len(t, tok, 1)
+		m := ModulateToken(tok[0])
+		assert.Equal(t, s, DemodulateToken(m).String())
+	}
+	assert.Equal(t, Int{V: 42}, DemodulateToken(modBig(big.NewInt(42))))
+}
+
+func TestDrawBigInt(t *testing.T) {
+	c := NewContext(nil)
+	_, err := TryParseString(c, "ap draw (ap ap vec 100000000000000000000 1)")
+	var e *TypeMismatch
+	require.True(t, errors.As(err, &e), "err: %v", err)
+	assert.Equal(t, "int64", e.Expected)
+}
+
+func TestSendFuncTransport(t *testing.T) {
+	var got []string
+	fake := FuncTransport(func(message string) (string, error) {
+		got = append(got, message)
+		return ModulateToken(Cons2{X0: Int{V: 1}, X1: Cons2{X0: Int{V: 42}, X1: Nil{}}}), nil
+	})
+	c := NewContext(nil, WithTransport(fake))
+	tok := ParseString(c, "ap car ap cdr ap send (1, 0)")
+	require.Len(t, tok, 1)
+	assert.Equal(t, Int{V: 42}, tok[0])
+	assert.Equal(t, []string{ModulateToken(Cons2{X0: Int{V: 1}, X1: Cons2{X0: Int{V: 0}, X1: Nil{}}})}, got)
+}
+
+func TestSendFileTransport(t *testing.T) {
+	ft, err := NewFileTransport(strings.NewReader(`# countdown
+1101000 110110000111011111100001001111110100110000
+`))
+	require.NoError(t, err)
+	c := NewContext(nil, WithTransport(ft))
+	tok := ParseString(c, "ap send (0)")
+	require.Len(t, tok, 1)
+	assert.Equal(t, "110110000111011111100001001111110100110000", ModulateToken(tok[0]))
+
+	_, err = TryParseString(c, "ap send (1)")
+	var e *SendError
+	assert.True(t, errors.As(err, &e), "err: %v", err)
+}
+
+func TestSendNoTransport(t *testing.T) {
+	c := NewContext(nil)
+	_, err := TryParseString(c, "ap send (0)")
+	var e *SendError
+	assert.True(t, errors.As(err, &e), "err: %v", err)
+}
+
+func TestRecordReplay(t *testing.T) {
+	fake := FuncTransport(func(message string) (string, error) {
+		return ModulateToken(Cons2{X0: Int{V: 1}, X1: Nil{}}), nil
+	})
+	var log bytes.Buffer
+	c := NewContext(nil, WithTransport(NewRecordingTransport(fake, &log)))
+	ParseString(c, "ap send (0)\nap send (1, 2)")
+
+	es, err := ReadExchanges(bytes.NewReader(log.Bytes()))
+	require.NoError(t, err)
+	require.Len(t, es, 2)
+	assert.Equal(t, "ap ap cons 0 nil", es[0].RequestGalaxy)
+	assert.Equal(t, "ap ap cons 1 nil", es[0].ResponseGalaxy)
+	assert.False(t, es[0].Time.IsZero())
+
+	rt := NewReplayTransport(es)
+	c = NewContext(nil, WithTransport(rt))
+	tok := ParseString(c, "ap send (0)\nap send (1, 3)")
+	require.Len(t, tok, 2)
+	assert.Equal(t, "ap ap cons 1 nil", tok[1].Galaxy())
+	assert.True(t, rt.Done())
+	require.Len(t, rt.Divergences, 1)
+	assert.Equal(t, 1, rt.Divergences[0].Index)
+
+	rt = NewReplayTransport(es)
+	rt.Strict = true
+	c = NewContext(nil, WithTransport(rt))
+	_, err = TryParseString(c, "ap send (1)")
+	var d *Divergence
+	assert.True(t, errors.As(err, &d), "err: %v", err)
+}
+
+func TestList(t *testing.T) {
+	l := List(Int{V: 1}, Pair(Int{V: 2}, Int{V: 3}), Nil{})
+	assert.Equal(t, "ap ap cons 1 ap ap cons ap ap cons 2 3 ap ap cons nil nil", l.Galaxy())
+
+	items, err := ListItems(DemodulateToken(ModulateToken(l)))
+	require.NoError(t, err)
+	assert.Equal(t, []Token{Int{V: 1}, Cons2{X0: Int{V: 2}, X1: Int{V: 3}}, Nil{}}, items)
+
+	_, err = ListItems(Pair(Int{V: 2}, Int{V: 3}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package interpreter
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	// contextCheckSteps is the number of steps between checks of the context.
+	contextCheckSteps = 1024
+	// traceLen is the number of innermost evaluations kept in LimitError.
+	traceLen = 8
+	// traceWidth is the length of a trace line.
+	traceWidth = 120
+)
+
+// enter starts the evaluation of t. An outermost evaluation starts a new step
+// budget.
+func (c *Ctx) enter(t Token) {
+	if c.CallLevel == 0 {
+		c.steps = 0
+		c.trace = c.trace[:0]
+		if c.Context != nil {
+			if err := c.Context.Err(); err != nil {
+				panic(&LimitError{Limit: LimitContext, Err: err})
+			}
+		}
+	}
+	// A failed enter has no leave to undo it, so check before entering.
+	if c.MaxDepth > 0 && c.CallLevel >= c.MaxDepth {
+		panic(&LimitError{Limit: LimitDepth, Value: c.MaxDepth, Trace: traceOf(append(c.trace, t))})
+	}
+	c.Enter()
+	c.trace = append(c.trace, t)
+}
+
+func (c *Ctx) leave() {
+	c.trace = c.trace[:len(c.trace)-1]
+	c.Leave()
+}
+
+// step counts a reduction step and returns the limit it exceeds, if any.
+func (c *Ctx) step() *LimitError {
+	c.EvalCount++
+	c.steps++
+	if c.MaxSteps > 0 && c.steps > c.MaxSteps {
+		return &LimitError{Limit: LimitSteps, Value: c.MaxSteps}
+	}
+	if c.Context != nil && c.steps%contextCheckSteps == 0 {
+		if err := c.Context.Err(); err != nil {
+			return &LimitError{Limit: LimitContext, Err: err}
+		}
+	}
+	return nil
+}
+
+// traceOf abbreviates the innermost tokens of an evaluation stack.
+func traceOf(ts []Token) []string {
+	if len(ts) > traceLen {
+		ts = ts[len(ts)-traceLen:]
+	}
+	r := make([]string, len(ts))
+	for i, t := range ts {
+		r[i] = Abbrev(t, traceWidth)
+	}
+	return r
+}
+
+// Abbrev returns the galaxy text of t cut after n bytes. Unlike Galaxy it
+// does not walk more of t than it prints.
+func Abbrev(t Token, n int) string {
+	var b strings.Builder
+	writeAbbrev(&b, t, n)
+	if b.Len() > n {
+		return b.String()[:n] + "..."
+	}
+	return b.String()
+}
+
+func writeAbbrev(b *strings.Builder, t Token, n int) {
+	if b.Len() > n {
+		return
+	}
+	switch t := t.(type) {
+	case *Thunk:
+		writeAbbrev(b, t.T, n)
+	case Ap2:
+		b.WriteString("ap ")
+		writeAbbrev(b, t.F, n)
+		b.WriteString(" ")
+		writeAbbrev(b, t.A, n)
+	default:
+		o, args, ok := primOf(t)
+		if !ok || len(args) == 0 {
+			b.WriteString(t.Galaxy())
+			return
+		}
+		b.WriteString(strings.Repeat("ap ", len(args)))
+		b.WriteString(opTokens[o].Galaxy())
+		for _, arg := range args {
+			b.WriteString(" ")
+			writeAbbrev(b, arg, n)
+		}
+	}
+}
+
+// ContextTransport is a Transport that can abandon a send when ctx is done.
+type ContextTransport interface {
+	Transport
+	SendContext(ctx context.Context, message string) (string, error)
+}
+
+// SendContext sends message through t until ctx is done. Sends of transports
+// that cannot be cancelled are left running in the background then.
+func SendContext(ctx context.Context, t Transport, message string) (string, error) {
+	if ct, ok := t.(ContextTransport); ok {
+		return ct.SendContext(ctx, message)
+	}
+	if ctx.Done() == nil {
+		return t.Send(message)
+	}
+	type result struct {
+		r   string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, err := t.Send(message)
+		done <- result{r: r, err: err}
+	}()
+	select {
+	case res := <-done:
+		return res.r, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
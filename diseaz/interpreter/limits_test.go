@@ -0,0 +1,152 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// :1 n = if n == 0 then 0 else 1 + :1(n - 1)
+const countdown = ":1 = ap ap s ap ap c ap eq 0 0 ap ap b ap add 1 ap ap b :1 ap add -1\n"
+
+func TestLimitSteps(t *testing.T) {
+	for _, graph := range []bool{false, true} {
+		c := NewContext(nil, WithGraph(graph), WithLimits(10000, 0))
+		_, err := TryParseString(c, ":1 = ap ap s i i\nap :1 :1")
+		var e *LimitError
+		require.True(t, errors.As(err, &e), "graph %v, err: %v", graph, err)
+		assert.Equal(t, LimitSteps, e.Limit)
+		assert.Equal(t, 10000, e.Value)
+		assert.NotEmpty(t, e.Trace)
+
+		// Every evaluation gets a fresh budget.
+		for i := 0; i < 3; i++ {
+			tok, err := TryParseString(c, countdown+"ap :1 100")
+			require.NoError(t, err, "graph %v", graph)
+			assert.Equal(t, []Token{Int{V: 100}}, tok)
+		}
+	}
+}
+
+func TestLimitDepth(t *testing.T) {
+	for _, graph := range []bool{false, true} {
+		c := NewContext(nil, WithGraph(graph), WithLimits(0, 100))
+		_, err := TryParseString(c, countdown+"ap :1 100000")
+		var e *LimitError
+		require.True(t, errors.As(err, &e), "graph %v, err: %v", graph, err)
+		assert.Equal(t, LimitDepth, e.Limit)
+		assert.Len(t, e.Trace, traceLen)
+		assert.Contains(t, e.Error(), "depth 100")
+
+		tok, err := TryParseString(c, "ap :1 10")
+		require.NoError(t, err, "graph %v", graph)
+		assert.Equal(t, []Token{Int{V: 10}}, tok)
+	}
+}
+
+func TestLimitReuse(t *testing.T) {
+	for _, graph := range []bool{false, true} {
+		c := NewContext(nil, WithGraph(graph), WithLimits(10000, 100))
+		_, err := TryParseString(c, countdown+"ap :1 100000")
+		var e *LimitError
+		require.True(t, errors.As(err, &e), "graph %v, err: %v", graph, err)
+		assert.Equal(t, LimitDepth, e.Limit)
+
+		_, err = TryParseString(c, ":2 = ap ap s i i\nap :2 :2")
+		require.True(t, errors.As(err, &e), "graph %v, err: %v", graph, err)
+		assert.Equal(t, LimitSteps, e.Limit)
+
+		// The failed evaluations left nothing behind, so the budgets are
+		// fresh for each of the evaluations that follow.
+		assert.Equal(t, 0, c.CallLevel)
+		for i := 0; i < 100; i++ {
+			tok, err := TryParseString(c, "ap :1 10")
+			require.NoError(t, err, "graph %v", graph)
+			assert.Equal(t, []Token{Int{V: 10}}, tok)
+		}
+	}
+}
+
+func TestLimitContext(t *testing.T) {
+	for _, graph := range []bool{false, true} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		c := NewContext(nil, WithGraph(graph))
+		c.Context = ctx
+		_, err := TryParseString(c, ":1 = ap ap s i i\nap :1 :1")
+		cancel()
+		var e *LimitError
+		require.True(t, errors.As(err, &e), "graph %v, err: %v", graph, err)
+		assert.Equal(t, LimitContext, e.Limit)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "err: %v", err)
+
+		_, err = TryParseString(c, "ap inc 1")
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "err: %v", err)
+	}
+}
+
+func TestLimitGraphValue(t *testing.T) {
+	// :4 n = cons n (:4 (n + 1)) is an infinite list, :5 a cyclic one.
+	const lists = `:4 = ap ap s cons ap ap b :4 inc
+:5 = ap ap cons 1 :5
+:2 = ap ap s ap ap c ap eq 0 nil ap ap s cons ap ap b :2 ap add -1
+:3 = ap :2 100
+`
+	c := NewContext(nil, WithGraph(true), WithLimits(10000, 0))
+	tok, err := TryParseString(c, lists+":5\n:3")
+	require.NoError(t, err)
+	require.Len(t, tok, 2)
+
+	// Converting the reduced list :3 into a token is charged as well.
+	c.MaxSteps = 50
+	_, err = TryParseString(c, ":3")
+	var e *LimitError
+	require.True(t, errors.As(err, &e), "err: %v", err)
+	assert.Equal(t, LimitSteps, e.Limit)
+	assert.NotEmpty(t, e.Trace)
+
+	c.MaxSteps, c.MaxDepth = 0, 10
+	_, err = TryParseString(c, ":3")
+	require.True(t, errors.As(err, &e), "err: %v", err)
+	assert.Equal(t, LimitDepth, e.Limit)
+
+	c.MaxDepth = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Context = ctx
+	for _, s := range []string{"ap :4 0", ":5"} {
+		_, err = TryParseString(c, s)
+		assert.True(t, errors.Is(err, context.Canceled), "%s: %v", s, err)
+	}
+
+	c = NewContext(nil, WithGraph(true), WithLimits(10000, 0))
+	_, err = TryParseString(c, lists+"ap :4 0")
+	require.True(t, errors.As(err, &e), "err: %v", err)
+	assert.Equal(t, LimitSteps, e.Limit)
+}
+
+func TestSendContext(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	stuck := FuncTransport(func(message string) (string, error) {
+		<-block
+		return "", nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := NewContext(nil, WithTransport(stuck))
+	c.Context = ctx
+	_, err := c.TrySendToken(List(Int{V: 0}))
+	var e *SendError
+	require.True(t, errors.As(err, &e), "err: %v", err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "err: %v", err)
+}
+
+func TestAbbrev(t *testing.T) {
+	tok := List(Ints(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)...)
+	assert.Equal(t, tok.Galaxy(), Abbrev(tok, 1000))
+	assert.Equal(t, "ap ap cons 1 ap ap cons 2 ap...", Abbrev(tok, 28))
+}
@@ -0,0 +1,33 @@
+package interpreter
+
+// List builds a nil-terminated cons list of ts.
+func List(ts ...Token) Token {
+	var r Token = Nil{}
+	for i := len(ts) - 1; i >= 0; i-- {
+		r = Cons2{X0: ts[i], X1: r}
+	}
+	return r
+}
+
+// Pair builds a cons pair, e.g. a vector.
+func Pair(x0, x1 Token) Token {
+	return Cons2{X0: x0, X1: x1}
+}
+
+// Ints converts vs to Int tokens.
+func Ints(vs ...int64) []Token {
+	r := make([]Token, len(vs))
+	for i, v := range vs {
+		r[i] = Int{V: v}
+	}
+	return r
+}
+
+// ListItems returns the items of an evaluated nil-terminated cons list.
+func ListItems(t Token) (ts []Token, err error) {
+	defer catchEvalError(&err)
+	for i := asCons(t); !i.IsNil(); i = asCons(i.Cdr()) {
+		ts = append(ts, i.Car())
+	}
+	return ts, nil
+}
@@ -0,0 +1,420 @@
+package interpreter
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// profileKey identifies a sample: the chain of definitions being evaluated,
+// outermost first and joined with ";", and the combinator reduced in it.
+type profileKey struct {
+	chain string
+	comb  string
+}
+
+type profileSample struct {
+	reductions int64
+	time       time.Duration
+}
+
+// Profiler is a Tracer that attributes reductions and wall time to the
+// galaxy definitions and combinators they are spent in.
+//
+// Reductions of an evaluation belong to the definition looked up last at its
+// level, or to the enclosing one. A function returned by a nested evaluation
+// takes its definition along to the evaluation that applies it. Only lazy
+// contexts evaluate definitions in nested evaluations of their own, eager
+// ones attribute the bodies of definitions to their callers. The graph
+// evaluator reduces shared definitions in place and reports each of them
+// once, so with it only the combinator counts are exact.
+type Profiler struct {
+	Start time.Time
+
+	defs    []int
+	names   map[int]string
+	chains  []string
+	samples map[profileKey]*profileSample
+	last    *profileSample
+	lastAt  time.Time
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{
+		Start:   time.Now(),
+		names:   make(map[int]string),
+		samples: make(map[profileKey]*profileSample),
+	}
+}
+
+// defName is the name of definition n in profiles.
+func (p *Profiler) defName(n int) string {
+	if name, ok := p.names[n]; ok {
+		return name
+	}
+	return VarN{N: n}.Galaxy()
+}
+
+// combinatorName is the name of the combinator reduced in the redex t.
+// Applications are named after the function applied if it is known by now.
+func combinatorName(t Token) string {
+	if o, _, ok := primOf(t); ok {
+		return opTokens[o].Galaxy()
+	}
+	switch t := t.(type) {
+	case Ap2:
+		f := t.F
+		for th, ok := f.(*Thunk); ok && th.Done; th, ok = f.(*Thunk) {
+			f = th.T
+		}
+		if o, _, ok := primOf(f); ok {
+			return opTokens[o].Galaxy()
+		}
+		return "ap"
+	case VarN:
+		return "var"
+	}
+	name := fmt.Sprintf("%T", t)
+	return strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+}
+
+// level makes the definition stack cover level l.
+func (p *Profiler) level(l int) {
+	for len(p.defs) <= l {
+		d, chain := -1, ""
+		if n := len(p.defs); n > 0 {
+			d, chain = p.defs[n-1], p.chains[n-1]
+		}
+		p.defs = append(p.defs, d)
+		p.chains = append(p.chains, chain)
+	}
+}
+
+// setDef makes n the definition evaluated at level l.
+func (p *Profiler) setDef(l, n int) {
+	p.level(l)
+	if p.defs[l] == n {
+		return
+	}
+	chain := ""
+	if l > 0 {
+		chain = p.chains[l-1]
+	}
+	if l == 0 || p.defs[l-1] != n {
+		if len(chain) > 0 {
+			chain += ";"
+		}
+		chain += p.defName(n)
+	}
+	p.defs[l] = n
+	p.chains[l] = chain
+}
+
+func (p *Profiler) sample(l int, comb string) *profileSample {
+	p.level(l)
+	k := profileKey{chain: p.chains[l], comb: comb}
+	s, ok := p.samples[k]
+	if !ok {
+		s = &profileSample{}
+		p.samples[k] = s
+	}
+	return s
+}
+
+func (p *Profiler) Trace(e *TraceEvent) {
+	now := time.Now()
+	if p.last != nil {
+		p.last.time += now.Sub(p.lastAt)
+	}
+	p.lastAt = now
+
+	l := e.Level
+	comb := ""
+	switch e.Kind {
+	case TraceEval:
+		if l < len(p.defs) {
+			p.defs = p.defs[:l]
+			p.chains = p.chains[:l]
+		}
+		p.level(l)
+	case TraceVar:
+		if v, ok := e.Token.(VarN); ok {
+			p.names[v.N] = v.Galaxy()
+			p.setDef(l, v.N)
+		}
+	case TraceResult:
+		p.level(l)
+		if _, ok := e.Result.(ICons); !ok && l > 0 && p.defs[l] >= 0 {
+			if _, ok := e.Result.(Func); ok {
+				p.setDef(l-1, p.defs[l])
+			}
+		}
+	case TraceApply:
+		comb = combinatorName(e.Token)
+	}
+	p.last = p.sample(l, comb)
+	if e.Kind == TraceApply {
+		p.last.reductions++
+	}
+}
+
+// ProfileEntry is a line of a flat profile. Reductions and Time are spent in
+// the entry itself, CumReductions and CumTime also in what it calls.
+type ProfileEntry struct {
+	Name          string
+	Reductions    int64
+	Time          time.Duration
+	CumReductions int64
+	CumTime       time.Duration
+}
+
+func sortEntries(m map[string]*ProfileEntry) []ProfileEntry {
+	r := make([]ProfileEntry, 0, len(m))
+	for _, e := range m {
+		r = append(r, *e)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Reductions != r[j].Reductions {
+			return r[i].Reductions > r[j].Reductions
+		}
+		if r[i].CumReductions != r[j].CumReductions {
+			return r[i].CumReductions > r[j].CumReductions
+		}
+		return r[i].Name < r[j].Name
+	})
+	return r
+}
+
+func entry(m map[string]*ProfileEntry, name string) *ProfileEntry {
+	e, ok := m[name]
+	if !ok {
+		e = &ProfileEntry{Name: name}
+		m[name] = e
+	}
+	return e
+}
+
+// Definitions returns the definitions by reductions spent in them.
+// Reductions outside of any definition belong to the empty name.
+func (p *Profiler) Definitions() []ProfileEntry {
+	m := make(map[string]*ProfileEntry)
+	for k, s := range p.samples {
+		var frames []string
+		if len(k.chain) > 0 {
+			frames = strings.Split(k.chain, ";")
+		}
+		self := entry(m, "")
+		if len(frames) > 0 {
+			self = entry(m, frames[len(frames)-1])
+		}
+		self.Reductions += s.reductions
+		self.Time += s.time
+		seen := make(map[string]bool)
+		for _, f := range frames {
+			if seen[f] {
+				continue
+			}
+			seen[f] = true
+			e := entry(m, f)
+			e.CumReductions += s.reductions
+			e.CumTime += s.time
+		}
+		if len(frames) == 0 {
+			self.CumReductions += s.reductions
+			self.CumTime += s.time
+		}
+	}
+	return sortEntries(m)
+}
+
+// Combinators returns the combinators by reductions spent in them. Time
+// between reductions belongs to the empty name.
+func (p *Profiler) Combinators() []ProfileEntry {
+	m := make(map[string]*ProfileEntry)
+	for k, s := range p.samples {
+		e := entry(m, k.comb)
+		e.Reductions += s.reductions
+		e.Time += s.time
+		e.CumReductions += s.reductions
+		e.CumTime += s.time
+	}
+	return sortEntries(m)
+}
+
+func writeEntries(w io.Writer, title string, es []ProfileEntry, n int) {
+	var total int64
+	for _, e := range es {
+		total += e.Reductions
+	}
+	if n > 0 && len(es) > n {
+		es = es[:n]
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "reductions\t%%\ttime\tcum reductions\tcum time\t %s\n", title)
+	for _, e := range es {
+		name := e.Name
+		if len(name) == 0 {
+			name = "(none)"
+		}
+		pct := 0.0
+		if total > 0 {
+			pct = 100 * float64(e.Reductions) / float64(total)
+		}
+		fmt.Fprintf(tw, "%d\t%.2f%%\t%s\t%d\t%s\t %s\n",
+			e.Reductions, pct, e.Time.Round(time.Microsecond),
+			e.CumReductions, e.CumTime.Round(time.Microsecond), name)
+	}
+	tw.Flush()
+}
+
+// WriteTop writes the top n definitions and combinators as tables, all of
+// them if n is zero.
+func (p *Profiler) WriteTop(w io.Writer, n int) {
+	writeEntries(w, "definition", p.Definitions(), n)
+	fmt.Fprintln(w)
+	writeEntries(w, "combinator", p.Combinators(), n)
+}
+
+// WritePprof writes the profile in the gzipped protobuf format of
+// `go tool pprof`. Definitions are its functions, with the reduced
+// combinators as leaves.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	var b protoBuffer
+	strs := map[string]uint64{"": 0}
+	var strTable []string
+	str := func(s string) uint64 {
+		i, ok := strs[s]
+		if !ok {
+			i = uint64(len(strs))
+			strs[s] = i
+			strTable = append(strTable, s)
+		}
+		return i
+	}
+	funcs := make(map[string]uint64)
+	var funcNames []string
+	fn := func(name string) uint64 {
+		id, ok := funcs[name]
+		if !ok {
+			id = uint64(len(funcs) + 1)
+			funcs[name] = id
+			funcNames = append(funcNames, name)
+		}
+		return id
+	}
+
+	valueType := func(field int, typ, unit string) {
+		var v protoBuffer
+		v.uint64(1, str(typ))
+		v.uint64(2, str(unit))
+		b.message(field, v)
+	}
+	valueType(1, "reductions", "count")
+	valueType(1, "time", "nanoseconds")
+
+	keys := make([]profileKey, 0, len(p.samples))
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].chain != keys[j].chain {
+			return keys[i].chain < keys[j].chain
+		}
+		return keys[i].comb < keys[j].comb
+	})
+	for _, k := range keys {
+		s := p.samples[k]
+		var locs []uint64
+		if len(k.comb) > 0 {
+			locs = append(locs, fn(k.comb))
+		}
+		if len(k.chain) > 0 {
+			frames := strings.Split(k.chain, ";")
+			for i := len(frames) - 1; i >= 0; i-- {
+				locs = append(locs, fn(frames[i]))
+			}
+		}
+		if len(locs) == 0 {
+			locs = append(locs, fn("(none)"))
+		}
+		var v protoBuffer
+		v.packed(1, locs)
+		v.packed(2, []uint64{uint64(s.reductions), uint64(s.time)})
+		b.message(2, v)
+	}
+
+	// Every function has a location with the same id.
+	for i, name := range funcNames {
+		id := uint64(i + 1)
+		var line protoBuffer
+		line.uint64(1, id)
+		var loc protoBuffer
+		loc.uint64(1, id)
+		loc.message(4, line)
+		b.message(4, loc)
+
+		var f protoBuffer
+		f.uint64(1, id)
+		f.uint64(2, str(name))
+		f.uint64(3, str(name))
+		b.message(5, f)
+	}
+
+	b.string(6, "")
+	for _, s := range strTable {
+		b.string(6, s)
+	}
+	b.uint64(9, uint64(p.Start.UnixNano()))
+	b.uint64(10, uint64(time.Since(p.Start)))
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// protoBuffer encodes the protobuf messages of a pprof profile.
+type protoBuffer []byte
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		*b = append(*b, byte(x)|0x80)
+		x >>= 7
+	}
+	*b = append(*b, byte(x))
+}
+
+func (b *protoBuffer) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.varint(uint64(field) << 3)
+	b.varint(x)
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(data)))
+	*b = append(*b, data...)
+}
+
+func (b *protoBuffer) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *protoBuffer) message(field int, m protoBuffer) {
+	b.bytes(field, m)
+}
+
+func (b *protoBuffer) packed(field int, xs []uint64) {
+	var p protoBuffer
+	for _, x := range xs {
+		p.varint(x)
+	}
+	b.bytes(field, p)
+}
@@ -0,0 +1,89 @@
+package interpreter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func findEntry(es []ProfileEntry, name string) (ProfileEntry, bool) {
+	for _, e := range es {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return ProfileEntry{}, false
+}
+
+func TestProfiler(t *testing.T) {
+	p := NewProfiler()
+	c := NewContext(nil, WithLazy(true), WithTracer(p))
+	tok, err := TryParseString(c, countdown+":2 = ap :1 10\nap ap add 1 :2")
+	require.NoError(t, err)
+	require.Equal(t, []Token{Int{V: 11}}, tok)
+
+	defs := p.Definitions()
+	d1, ok := findEntry(defs, ":1")
+	require.True(t, ok, "%v", defs)
+	d2, ok := findEntry(defs, ":2")
+	require.True(t, ok, "%v", defs)
+	// The countdown runs in :1, called from :2.
+	assert.Greater(t, d1.Reductions, d2.Reductions)
+	assert.GreaterOrEqual(t, d2.CumReductions, d1.CumReductions)
+	assert.Equal(t, ":1", defs[0].Name)
+
+	var total int64
+	for _, e := range defs {
+		total += e.Reductions
+	}
+	var combTotal int64
+	for _, e := range p.Combinators() {
+		combTotal += e.Reductions
+	}
+	assert.Equal(t, total, combTotal)
+	for _, name := range []string{"s", "c", "b", "add"} {
+		e, ok := findEntry(p.Combinators(), name)
+		if assert.True(t, ok, name) {
+			assert.Greater(t, e.Reductions, int64(0), name)
+		}
+	}
+
+	var out bytes.Buffer
+	p.WriteTop(&out, 3)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 9, out.String())
+	assert.Contains(t, lines[0], "definition")
+	assert.True(t, strings.HasSuffix(lines[1], " :1"), lines[1])
+	assert.Contains(t, lines[5], "combinator")
+}
+
+func TestProfilerPprof(t *testing.T) {
+	p := NewProfiler()
+	c := NewContext(nil, WithLazy(true), WithTracer(p))
+	_, err := TryParseString(c, countdown+"ap :1 10")
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, p.WritePprof(&out))
+	r, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	for _, s := range []string{"reductions", "count", "time", "nanoseconds", ":1", "add"} {
+		assert.Contains(t, string(data), s)
+	}
+}
+
+func TestProtoBuffer(t *testing.T) {
+	var b protoBuffer
+	b.uint64(1, 150)
+	b.uint64(2, 0)
+	b.string(3, "ab")
+	b.packed(4, []uint64{3, 270})
+	assert.Equal(t, protoBuffer{0x08, 0x96, 0x01, 0x1a, 2, 'a', 'b', 0x22, 3, 3, 0x8e, 0x02}, b)
+}
@@ -50,7 +50,7 @@ func (p *Program) Pops() (Token, bool) {
 func (p *Program) Pop() Token {
 	r, ok := p.Pops()
 	if !ok {
-		panic(fmt.Errorf("Pop from empty program"))
+		panic(&ParseError{Text: p.String(), Reason: "pop from empty program"})
 	}
 	return r
 }
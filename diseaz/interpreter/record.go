@@ -0,0 +1,176 @@
+package interpreter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Exchange is one recorded request and response pair of Ctx.Send.
+type Exchange struct {
+	Time           time.Time
+	Request        string
+	Response       string
+	RequestGalaxy  string `json:",omitempty"`
+	ResponseGalaxy string `json:",omitempty"`
+	Error          string `json:",omitempty"`
+}
+
+// galaxyOf returns the demodulated form of a signal or an empty string if it
+// is not a valid modulation.
+func galaxyOf(signal string) string {
+	t, err := TryDemodulateToken(signal)
+	if err != nil {
+		return ""
+	}
+	return t.Galaxy()
+}
+
+// RecordingTransport passes messages to Next and appends every exchange to W
+// as a JSON line.
+type RecordingTransport struct {
+	Next Transport
+	W    io.Writer
+	mu   sync.Mutex
+}
+
+func NewRecordingTransport(next Transport, w io.Writer) *RecordingTransport {
+	return &RecordingTransport{Next: next, W: w}
+}
+
+func (t *RecordingTransport) Send(message string) (string, error) {
+	return t.SendContext(context.Background(), message)
+}
+
+func (t *RecordingTransport) SendContext(ctx context.Context, message string) (string, error) {
+	var r string
+	err := fmt.Errorf("no transport")
+	if t.Next != nil {
+		r, err = SendContext(ctx, t.Next, message)
+	}
+
+	e := Exchange{
+		Time:           time.Now(),
+		Request:        message,
+		Response:       r,
+		RequestGalaxy:  galaxyOf(message),
+		ResponseGalaxy: galaxyOf(r),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if werr := json.NewEncoder(t.W).Encode(e); werr != nil {
+		log.Printf("Recording failed: %s", werr)
+	}
+	return r, err
+}
+
+// ReadExchanges reads exchanges written by RecordingTransport.
+func ReadExchanges(rd io.Reader) ([]Exchange, error) {
+	var es []Exchange
+	d := json.NewDecoder(rd)
+	for {
+		var e Exchange
+		err := d.Decode(&e)
+		if err == io.EOF {
+			return es, nil
+		}
+		if err != nil {
+			return es, err
+		}
+		es = append(es, e)
+	}
+}
+
+// Divergence is a replayed request that differs from the recorded one.
+type Divergence struct {
+	Index    int
+	Expected string
+	Got      string
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("Replay diverged at exchange %d:\nexpected %s\ngot      %s",
+		d.Index, galaxyOrSignal(d.Expected), galaxyOrSignal(d.Got))
+}
+
+func galaxyOrSignal(s string) string {
+	if g := galaxyOf(s); g != "" {
+		return g
+	}
+	return fmt.Sprintf("%#v", s)
+}
+
+// ReplayTransport serves recorded responses in order. Requests that differ
+// from the recording are collected in Divergences; in Strict mode they fail
+// the send instead.
+type ReplayTransport struct {
+	Exchanges   []Exchange
+	Strict      bool
+	Divergences []*Divergence
+	pos         int
+	mu          sync.Mutex
+}
+
+func NewReplayTransport(es []Exchange) *ReplayTransport {
+	return &ReplayTransport{Exchanges: es}
+}
+
+func OpenReplayTransport(fn string) (*ReplayTransport, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	es, err := ReadExchanges(f)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayTransport(es), nil
+}
+
+func (t *ReplayTransport) Send(message string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pos >= len(t.Exchanges) {
+		return "", fmt.Errorf("Replay exhausted after %d exchanges", len(t.Exchanges))
+	}
+	e := t.Exchanges[t.pos]
+	if e.Request != message {
+		d := &Divergence{Index: t.pos, Expected: e.Request, Got: message}
+		t.Divergences = append(t.Divergences, d)
+		if t.Strict {
+			return "", d
+		}
+		log.Print(d)
+	}
+	t.pos++
+
+	if e.Error != "" {
+		return e.Response, fmt.Errorf("%s", e.Error)
+	}
+	return e.Response, nil
+}
+
+// Diverged returns the divergences so far.
+func (t *ReplayTransport) Diverged() []*Divergence {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Divergence(nil), t.Divergences...)
+}
+
+// Done reports whether every recorded exchange was replayed.
+func (t *ReplayTransport) Done() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pos == len(t.Exchanges)
+}
@@ -0,0 +1,118 @@
+package interpreter
+
+import (
+	"io"
+)
+
+// GalaxyProtocol is the entry point of galaxy.txt (`galaxy = :1338`).
+var GalaxyProtocol Token = VarN{N: 1338}
+
+// Frame is a galaxy state together with the pictures that led to it.
+type Frame struct {
+	State Token
+	Pics  []*Picture
+}
+
+// Session keeps a parsed galaxy resident and runs interact clicks against it.
+type Session struct {
+	Ctx      *Ctx
+	Protocol Token
+	Initial  Token
+	State    Token
+	Pics     []*Picture
+	History  []Frame
+	Future   []Frame
+}
+
+// NewSession loads galaxy definitions from rd into c.
+func NewSession(c *Ctx, rd io.Reader) (*Session, error) {
+	_, err := TryParseReader(c, rd)
+	if err != nil {
+		return nil, err
+	}
+	return NewContextSession(c), nil
+}
+
+// NewContextSession starts a session on definitions already loaded into c.
+func NewContextSession(c *Ctx) *Session {
+	return &Session{
+		Ctx:      c,
+		Protocol: GalaxyProtocol,
+		Initial:  Nil{},
+		State:    Nil{},
+	}
+}
+
+// Click runs `interact protocol state (vec x y)` including the send loop.
+// It returns the new state and the pictures of every multipledraw layer.
+// On success the session moves to the new state and remembers state, so
+// that Undo returns to it.
+func (s *Session) Click(state Token, x, y int) (newState Token, pics []*Picture, err error) {
+	return s.click(Frame{State: state}, x, y)
+}
+
+// ClickCurrent clicks at (x, y) from the current session state.
+func (s *Session) ClickCurrent(x, y int) (Token, []*Picture, error) {
+	return s.click(s.Frame(), x, y)
+}
+
+func (s *Session) click(from Frame, x, y int) (newState Token, pics []*Picture, err error) {
+	s.Ctx.Pic = NewPicture()
+	point := Cons2{X0: Int{V: int64(x)}, X1: Int{V: int64(y)}}
+	rs, err := s.Ctx.TryEval(s.Ctx.Ap(s.Ctx.Ap(s.Ctx.Ap(Interact{}, s.Protocol), from.State), point))
+	if err != nil {
+		return nil, nil, err
+	}
+	res, ok := rs.(ICons)
+	if !ok || res.IsNil() {
+		return nil, nil, &TypeMismatch{Expected: "interact result", Got: rs}
+	}
+	newState = res.Car()
+	if tail, ok := res.Cdr().(ICons); ok && !tail.IsNil() {
+		if pic, ok := tail.Car().(*Picture); ok {
+			for _, layer := range pic.Draw {
+				pics = append(pics, NewPicture().DrawPts(layer...))
+			}
+		}
+	}
+
+	s.History = append(s.History, from)
+	s.Future = nil
+	s.State, s.Pics = newState, pics
+	return newState, pics, nil
+}
+
+// Frame returns the current state and pictures.
+func (s *Session) Frame() Frame {
+	return Frame{State: s.State, Pics: s.Pics}
+}
+
+// Undo restores the state before the last click.
+func (s *Session) Undo() (Token, bool) {
+	if len(s.History) == 0 {
+		return s.State, false
+	}
+	s.Future = append(s.Future, s.Frame())
+	f := s.History[len(s.History)-1]
+	s.History = s.History[:len(s.History)-1]
+	s.State, s.Pics = f.State, f.Pics
+	return s.State, true
+}
+
+// Redo reapplies the last undone click.
+func (s *Session) Redo() (Token, bool) {
+	if len(s.Future) == 0 {
+		return s.State, false
+	}
+	s.History = append(s.History, s.Frame())
+	f := s.Future[len(s.Future)-1]
+	s.Future = s.Future[:len(s.Future)-1]
+	s.State, s.Pics = f.State, f.Pics
+	return s.State, true
+}
+
+// Reset returns to the initial state and forgets the history.
+func (s *Session) Reset() {
+	s.State, s.Pics = s.Initial, nil
+	s.History, s.Future = nil, nil
+}
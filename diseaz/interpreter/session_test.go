@@ -0,0 +1,71 @@
+package interpreter
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadGalaxy(t testing.TB) *Session {
+	f, err := os.Open("../galaxy.txt")
+	require.NoError(t, err)
+	defer f.Close()
+	s, err := NewSession(NewContext(nil), f)
+	require.NoError(t, err)
+	return s
+}
+
+func TestSessionClick(t *testing.T) {
+	s := loadGalaxy(t)
+
+	state, pics, err := s.ClickCurrent(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "ap ap cons 0 ap ap cons ap ap cons 0 nil ap ap cons 0 ap ap cons nil nil", state.Galaxy())
+	require.Len(t, pics, 3)
+	assert.NotEmpty(t, pics[0].Pts)
+
+	state, _, err = s.ClickCurrent(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "ap ap cons 0 ap ap cons ap ap cons 1 nil ap ap cons 0 ap ap cons nil nil", state.Galaxy())
+	assert.Len(t, s.History, 2)
+
+	prev, ok := s.Undo()
+	assert.True(t, ok)
+	assert.Equal(t, "ap ap cons 0 ap ap cons ap ap cons 0 nil ap ap cons 0 ap ap cons nil nil", prev.Galaxy())
+	prev, ok = s.Undo()
+	assert.True(t, ok)
+	assert.Equal(t, Nil{}, prev)
+	_, ok = s.Undo()
+	assert.False(t, ok)
+
+	next, ok := s.Redo()
+	assert.True(t, ok)
+	assert.Equal(t, "ap ap cons 0 ap ap cons ap ap cons 0 nil ap ap cons 0 ap ap cons nil nil", next.Galaxy())
+	assert.Len(t, s.Pics, 3)
+	assert.Len(t, s.Future, 1)
+
+	s.Reset()
+	assert.Equal(t, Nil{}, s.State)
+	assert.Empty(t, s.History)
+	assert.Empty(t, s.Future)
+}
+
+func TestSessionClickState(t *testing.T) {
+	s := loadGalaxy(t)
+
+	first, _, err := s.ClickCurrent(0, 0)
+	require.NoError(t, err)
+	s.Reset()
+
+	state, _, err := s.Click(first, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "ap ap cons 0 ap ap cons ap ap cons 1 nil ap ap cons 0 ap ap cons nil nil", state.Galaxy())
+
+	// Undo returns to the clicked state, not to the state before the click.
+	prev, ok := s.Undo()
+	assert.True(t, ok)
+	assert.Equal(t, first, prev)
+	assert.Nil(t, s.Pics)
+}
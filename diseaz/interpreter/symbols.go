@@ -0,0 +1,107 @@
+package interpreter
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Symbols numbers the named variables of a context or of shared
+// Definitions. Names get negative numbers, so they never clash with the `:N`
+// variables of galaxy.txt. The symbols of a context on shared definitions
+// extend the symbols of the definitions.
+type Symbols struct {
+	parent *Symbols
+	byName map[string]int
+	names  map[int]string
+}
+
+// NewSymbols returns an empty table that extends parent. Parent must not
+// number new names any more.
+func NewSymbols(parent *Symbols) *Symbols {
+	return &Symbols{
+		parent: parent,
+		byName: make(map[string]int),
+		names:  make(map[int]string),
+	}
+}
+
+// IsName tells whether s is a variable name: a letter or `_` followed by
+// letters, digits and `_`. Names of built-in tokens other than ap are
+// variable names too, definitions shadow the built-in tokens.
+func IsName(s string) bool {
+	if len(s) == 0 || s == "ap" {
+		return false
+	}
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// declare numbers the names defined by lines, so that they may be used
+// before their definitions.
+func declare(syms *Symbols, lines []string) {
+	for _, line := range lines {
+		toks := strings.Fields(line)
+		if len(toks) >= 3 && toks[1] == "=" && IsName(toks[0]) {
+			syms.Symbol(toks[0])
+		}
+	}
+}
+
+// Lookup returns the variable named name if it is numbered already.
+func (s *Symbols) Lookup(name string) (VarN, bool) {
+	for ; s != nil; s = s.parent {
+		if n, ok := s.byName[name]; ok {
+			return VarN{N: n, Name: name}, true
+		}
+	}
+	return VarN{}, false
+}
+
+// Symbol returns the variable named name, numbering new names.
+func (s *Symbols) Symbol(name string) VarN {
+	if v, ok := s.Lookup(name); ok {
+		return v
+	}
+	n := -s.len() - 1
+	s.byName[name] = n
+	s.names[n] = name
+	return VarN{N: n, Name: name}
+}
+
+func (s *Symbols) len() int {
+	l := 0
+	for ; s != nil; s = s.parent {
+		l += len(s.byName)
+	}
+	return l
+}
+
+// Var returns variable n with its name if it has one.
+func (s *Symbols) Var(n int) VarN {
+	for ; n < 0 && s != nil; s = s.parent {
+		if name, ok := s.names[n]; ok {
+			return VarN{N: n, Name: name}
+		}
+	}
+	return VarN{N: n}
+}
+
+// Sort sorts variable numbers: `:N` variables by number, then named ones by
+// name.
+func (s *Symbols) Sort(ns []int) {
+	sort.Slice(ns, func(i, j int) bool {
+		a, b := ns[i], ns[j]
+		if (a >= 0) != (b >= 0) {
+			return a >= 0
+		}
+		if a >= 0 {
+			return a < b
+		}
+		return s.Var(a).Galaxy() < s.Var(b).Galaxy()
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"io"
 	"log"
+	"math/big"
 	"strconv"
 	"strings"
 	"unicode"
@@ -17,13 +18,24 @@ func makeTokenMap(ts ...Token) map[string]Token {
 	return r
 }
 
-func ParseVarN(s string) Token {
+// ParseVarN parses a `:N` variable or a variable name known to syms. Other
+// names are left to the built-in tokens.
+func ParseVarN(syms *Symbols, s string) Token {
+	if IsName(s) {
+		if v, ok := syms.Lookup(s); ok {
+			return v
+		}
+		return nil
+	}
 	if !strings.HasPrefix(s, ":") {
 		return nil
 	}
 	n, err := strconv.ParseInt(s[1:], 10, 64)
 	if err != nil {
-		log.Panic(err)
+		panic(&ParseError{Text: s, Reason: "invalid variable name", Err: err})
+	}
+	if n < 0 {
+		panic(&ParseError{Text: s, Reason: "negative variable number"})
 	}
 	return VarN{N: int(n)}
 }
@@ -34,11 +46,11 @@ func ParseInt(s string) Token {
 	if (sign && idx != 1) || (!sign && idx != 0) {
 		return nil
 	}
-	n, err := strconv.ParseInt(s, 10, 64)
-	if err != nil {
-		log.Panic(err)
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic(&ParseError{Text: s, Reason: "invalid number"})
 	}
-	return Int{V: n}
+	return NewBigInt(n)
 }
 
 func IsComment(s string) bool {
@@ -49,6 +61,16 @@ func IsComment(s string) bool {
 }
 
 func ProcessTokens(c Context, toks []string) Token {
+	tok, err := Interpret(c, ParseProgram(c.Symbols(), toks))
+	if err != nil {
+		panic(err)
+	}
+	return tok
+}
+
+// ParseProgram parses the tokens of an expression into a program for
+// Interpret. Variable names are numbered in syms.
+func ParseProgram(syms *Symbols, toks []string) Program {
 	toks = splitOn(toks, "(")
 	toks = splitOn(toks, ")")
 	toks = splitOn(toks, ",")
@@ -72,7 +94,7 @@ func ProcessTokens(c Context, toks []string) Token {
 		}
 
 		empty = false
-		t := ParseVarN(ts)
+		t := ParseVarN(syms, ts)
 		if t != nil {
 			p.Push(t)
 			continue
@@ -87,17 +109,12 @@ func ProcessTokens(c Context, toks []string) Token {
 			p.Push(t)
 			continue
 		}
-		log.Panicf("Unknown token: %#v", ts)
+		panic(&ParseError{Text: ts, Reason: "unknown token"})
 	}
 
 	// log.Printf("Program: %#v", p)
 
-	tok, err := Interpret(c, p)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	return tok
+	return p
 }
 
 func splitOn(toks []string, sep string) []string {
@@ -118,6 +135,27 @@ func splitOn(toks []string, sep string) []string {
 	return r
 }
 
+// splitAssign splits the tokens of a `:N = expr` or `name = expr` line.
+func splitAssign(syms *Symbols, toks []string) (VarN, []string, bool) {
+	if len(toks) < 3 || toks[1] != "=" {
+		return VarN{}, toks, false
+	}
+	if toks[0] == "ap" {
+		panic(&ParseError{Text: toks[0], Reason: "ap redefined"})
+	}
+	if IsName(toks[0]) {
+		if _, builtin := tokenMap[toks[0]]; builtin {
+			log.Printf("Warning: %s shadows a built-in token", toks[0])
+		}
+		return syms.Symbol(toks[0]), toks[2:], true
+	}
+	v, ok := ParseVarN(syms, toks[0]).(VarN)
+	if !ok {
+		return VarN{}, toks, false
+	}
+	return v, toks[2:], true
+}
+
 func ParseLine(c Context, s string) []Token {
 	toks := strings.Fields(s)
 	if len(toks) == 0 || IsComment(toks[0]) {
@@ -125,13 +163,10 @@ func ParseLine(c Context, s string) []Token {
 	}
 	log.Printf("Run: %s", s)
 
-	assign := false
-	var varN VarN
-
-	if len(toks) > 2 && toks[1] == "=" && ParseVarN(toks[0]) != nil {
-		varN, assign = ParseVarN(toks[0]).(VarN), true
-		toks = toks[2:]
-		// log.Printf("Assign %s = %s", varN, strings.Join(toks, " "))
+	varN, toks, assign := splitAssign(c.Symbols(), toks)
+	if cc, ok := c.(*Ctx); ok && assign {
+		cc.Define(varN.N, ParseProgram(c.Symbols(), toks))
+		return nil
 	}
 
 	tok := ProcessTokens(c, toks)
@@ -148,19 +183,49 @@ func ParseLine(c Context, s string) []Token {
 	return []Token{r}
 }
 
+// TryParseLine is ParseLine that returns evaluation errors instead of
+// panicking.
+func TryParseLine(c Context, s string) (ts []Token, err error) {
+	defer catchEvalError(&err)
+	return ParseLine(c, s), nil
+}
+
+// ParseReader runs the lines of rd. Names defined in rd may be used before
+// their definitions.
 func ParseReader(c Context, rd io.Reader) []Token {
-	lrd := bufio.NewReader(rd)
+	lines := readLines(rd)
+	declare(c.Symbols(), lines)
 	var rs []Token
-	for line, err := lrd.ReadString('\n'); err != io.EOF || len(line) > 0; line, err = lrd.ReadString('\n') {
+	for _, line := range lines {
 		rs = append(rs, ParseLine(c, line)...)
 	}
 	return rs
 }
 
+func readLines(rd io.Reader) []string {
+	lrd := bufio.NewReader(rd)
+	var lines []string
+	for line, err := lrd.ReadString('\n'); err != io.EOF || len(line) > 0; line, err = lrd.ReadString('\n') {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
 func ParseString(c Context, s string) []Token {
 	return ParseReader(c, strings.NewReader(s))
 }
 
+// TryParseReader is ParseReader that returns evaluation errors instead of
+// panicking.
+func TryParseReader(c Context, rd io.Reader) (ts []Token, err error) {
+	defer catchEvalError(&err)
+	return ParseReader(c, rd), nil
+}
+
+func TryParseString(c Context, s string) ([]Token, error) {
+	return TryParseReader(c, strings.NewReader(s))
+}
+
 var tokenMap = makeTokenMap(
 	Ap{},
 	Inc{},
@@ -3,6 +3,8 @@ package interpreter
 import (
 	"fmt"
 	"log"
+	"math"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -52,7 +54,7 @@ func (t Ap2) Apply(v Token) Token {
 }
 
 func (t Ap2) Eval(c Context) (Token, bool) {
-	f := c.Eval(t.F).(Func)
+	f := evalFunc(c, t.F)
 	r := f.Apply(t.A)
 	// log.Printf("%s => %s", t, r)
 	return r, true
@@ -66,8 +68,61 @@ func (t Ap2) String() string {
 	return fmt.Sprintf("(ap %s %s)", t.F, t.A)
 }
 
+// Thunk is a shared application node for call-by-need evaluation.
+// The wrapped token is reduced on the first Eval and the result is reused
+// by every other reference to the same thunk.
+type Thunk struct {
+	T    Token
+	Done bool
+}
+
+func NewThunk(t Token) *Thunk {
+	return &Thunk{T: t}
+}
+
+func (t *Thunk) Eval(c Context) (Token, bool) {
+	if !t.Done {
+		t.T = c.Eval(t.T)
+		t.Done = true
+	}
+	return t.T, false
+}
+
+// evalShared evaluates t. Shared thunks are kept in place after forcing and
+// evaluated lists are wrapped into forced thunks, so re-evaluating the
+// enclosing value stops at them instead of walking the whole list again.
+func evalShared(c Context, t Token) Token {
+	if th, ok := t.(*Thunk); ok {
+		c.Eval(th)
+		return th
+	}
+	r := c.Eval(t)
+	if _, ok := r.(Cons2); ok {
+		return &Thunk{T: r, Done: true}
+	}
+	return r
+}
+
+// unthunk returns the cached value of an already forced thunk.
+func unthunk(t Token) Token {
+	if th, ok := t.(*Thunk); ok && th.Done {
+		return th.T
+	}
+	return t
+}
+
+func (t *Thunk) String() string {
+	return t.T.String()
+}
+
+func (t *Thunk) Galaxy() string {
+	return t.T.Galaxy()
+}
+
 type VarN struct {
 	N int
+	// Name is the name of a named variable, empty for `:N`.
+	Name string
 }
 
 func (t VarN) Eval(c Context) (Token, bool) {
@@ -75,18 +130,29 @@ func (t VarN) Eval(c Context) (Token, bool) {
 }
 
 func (t VarN) String() string {
-	return fmt.Sprintf(":%d", t.N)
+	return t.Galaxy()
 }
 
 func (t VarN) Galaxy() string {
-	return fmt.Sprintf(":%d", t.N)
+	if len(t.Name) > 0 {
+		return t.Name
+	}
+	return ":" + strconv.Itoa(t.N)
 }
 
+// Int is an integer literal. Values that do not fit into int64 are kept in B
+// and V is zero then.
 type Int struct {
 	V int64
+	B *big.Int
 }
 
+// Value returns the value of t. It panics with TypeMismatch if the value does
+// not fit into int64.
 func (t Int) Value() int64 {
+	if t.B != nil {
+		panic(&TypeMismatch{Expected: "int64", Got: t})
+	}
 	return t.V
 }
 
@@ -95,11 +161,14 @@ func (t Int) Eval(c Context) (Token, bool) {
 }
 
 func (t Int) String() string {
+	if t.B != nil {
+		return t.B.String()
+	}
 	return strconv.FormatInt(t.V, 10)
 }
 
 func (t Int) Galaxy() string {
-	return strconv.FormatInt(t.V, 10)
+	return t.String()
 }
 
 type Inc struct{}
@@ -112,8 +181,8 @@ func (t Inc) Apply(v Token) Token {
 }
 
 func (t Inc1) Eval(c Context) (Token, bool) {
-	v := c.Eval(t.X0).(Int)
-	r := Int{V: v.V + 1}
+	v := evalInt(c, t.X0)
+	r := addInts(v, Int{V: 1})
 	// log.Printf("%s => %s", t, r)
 	return r, false
 }
@@ -148,8 +217,8 @@ func (t Dec) Apply(v Token) Token {
 }
 
 func (t Dec1) Eval(c Context) (Token, bool) {
-	v := c.Eval(t.X0).(Int)
-	r := Int{V: v.V - 1}
+	v := evalInt(c, t.X0)
+	r := addInts(v, Int{V: -1})
 	// log.Printf("%s => %s", t, r)
 	return r, false
 }
@@ -192,9 +261,9 @@ func (t Add1) Apply(v Token) Token {
 }
 
 func (t Add2) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Int)
-	x1 := c.Eval(t.X1).(Int)
-	r := Int{V: x0.V + x1.V}
+	x0 := evalInt(c, t.X0)
+	x1 := evalInt(c, t.X1)
+	r := addInts(x0, x1)
 	// log.Printf("%s => %s", t, r)
 	return r, false
 }
@@ -249,9 +318,9 @@ func (t Mul1) Apply(v Token) Token {
 }
 
 func (t Mul2) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Int)
-	x1 := c.Eval(t.X1).(Int)
-	r := Int{V: x0.V * x1.V}
+	x0 := evalInt(c, t.X0)
+	x1 := evalInt(c, t.X1)
+	r := mulInts(x0, x1)
 	// log.Printf("%s => %s", t, r)
 	return r, false
 }
@@ -306,9 +375,9 @@ func (t Div1) Apply(v Token) Token {
 }
 
 func (t Div2) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Int)
-	x1 := c.Eval(t.X1).(Int)
-	r := Int{V: x0.V / x1.V}
+	x0 := evalInt(c, t.X0)
+	x1 := evalInt(c, t.X1)
+	r := divInts(x0, x1)
 	// log.Printf("%s => %s", t, r)
 	return r, false
 }
@@ -363,10 +432,10 @@ func (t Eq1) Apply(v Token) Token {
 }
 
 func (t Eq2) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Int)
-	x1 := c.Eval(t.X1).(Int)
+	x0 := evalInt(c, t.X0)
+	x1 := evalInt(c, t.X1)
 	var r Token = False{}
-	if x0.V == x1.V {
+	if x0.Cmp(x1) == 0 {
 		r = True{}
 	}
 	// log.Printf("%s => %s", t, r)
@@ -423,10 +492,10 @@ func (t Lt1) Apply(v Token) Token {
 }
 
 func (t Lt2) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Int)
-	x1 := c.Eval(t.X1).(Int)
+	x0 := evalInt(c, t.X0)
+	x1 := evalInt(c, t.X1)
 	var r Token = False{}
-	if x0.V < x1.V {
+	if x0.Cmp(x1) < 0 {
 		r = True{}
 	}
 	// log.Printf("%s => %s", t, r)
@@ -468,26 +537,45 @@ func (t Lt2) Galaxy() string {
 func ModulateToken(v Token) string {
 	switch tt := v.(type) { // TailEval?
 	case Int:
+		if tt.B != nil {
+			return modBig(tt.B)
+		}
 		return modInt(tt.V)
 	case ICons:
 		return modCons(tt)
 	default:
-		log.Panicf("Invalid `modulate` argument: %s", v)
+		panic(&TypeMismatch{Expected: "int or list", Got: v})
 	}
-	return "ERROR"
 }
 
 func modInt(v int64) string {
 	if v == 0 {
 		return "010"
 	}
+	if v == math.MinInt64 {
+		return modBig(big.NewInt(v))
+	}
 	prefix := "01"
 	if v < 0 {
 		prefix = "10"
 		v = -v
 	}
+	return modBits(prefix, strconv.FormatInt(v, 2))
+}
+
+func modBig(v *big.Int) string {
+	if v.Sign() == 0 {
+		return "010"
+	}
+	prefix := "01"
+	if v.Sign() < 0 {
+		prefix = "10"
+	}
+	return modBits(prefix, new(big.Int).Abs(v).Text(2))
+}
+
+func modBits(prefix string, s string) string {
 	rs := []string{prefix}
-	s := strconv.FormatInt(v, 2)
 	n := (len(s) + 3) / 4
 	rs = append(rs, strings.Repeat("1", n), "0")
 	m := (4 * n) - len(s)
@@ -508,14 +596,21 @@ func modCons(v ICons) string {
 func DemodulateToken(v string) Token {
 	r, s := demodToken(v)
 	if len(s) > 0 {
-		log.Panicf("Extra tail on demod %s\n=> %s\n++ %s", v, r, s)
+		panic(&BadModulation{Signal: v, Reason: fmt.Sprintf("extra tail %#v after %s", s, r)})
 	}
 	return r
 }
 
+// TryDemodulateToken is DemodulateToken that returns BadModulation instead of
+// panicking.
+func TryDemodulateToken(v string) (t Token, err error) {
+	defer catchEvalError(&err)
+	return DemodulateToken(v), nil
+}
+
 func demodToken(v string) (Token, string) {
-	if len(v) == 0 {
-		return nil, v
+	if len(v) < 2 {
+		panic(&BadModulation{Signal: v, Reason: "truncated signal"})
 	}
 	prefix, w := v[0:2], v[2:]
 	if prefix == "00" {
@@ -538,25 +633,28 @@ func demodInt(v string) (Token, string) {
 	case "10":
 		negative = true
 	default:
-		log.Panicf("Invalid modulated int prefix: %#v", v)
+		panic(&BadModulation{Signal: v, Reason: "invalid int prefix"})
 	}
 	nlen := 0
-	for ; w[0] == '1'; w = w[1:] {
+	for ; len(w) > 0 && w[0] == '1'; w = w[1:] {
 		nlen += 4
 	}
+	if len(w) < nlen+1 {
+		panic(&BadModulation{Signal: v, Reason: "truncated int"})
+	}
 	w = w[1:]
 	if nlen == 0 {
 		return Int{V: 0}, w
 	}
 	num, w := w[:nlen], w[nlen:]
-	n, err := strconv.ParseInt(num, 2, 64)
-	if err != nil {
-		log.Panic(err)
+	n, ok := new(big.Int).SetString(num, 2)
+	if !ok {
+		panic(&BadModulation{Signal: v, Reason: "invalid int bits"})
 	}
 	if negative {
-		n = -n
+		n.Neg(n)
 	}
-	return Int{V: n}, w
+	return NewBigInt(n), w
 }
 
 type Signal struct {
@@ -621,7 +719,7 @@ func (t Demodulate) Apply(v Token) Token {
 }
 
 func (t Demodulate1) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Signal).S
+	x0 := asSignal(c.Eval(t.X0)).S
 	r := DemodulateToken(x0)
 	// log.Printf("%s => %s", t, r)
 	return r, false
@@ -699,8 +797,8 @@ func (t Neg) Eval(c Context) (Token, bool) {
 }
 
 func (t Neg1) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Int)
-	r := Int{V: -x0.V}
+	x0 := evalInt(c, t.X0)
+	r := negInt(x0)
 	// log.Printf("%s => %s", t, r)
 	return r, false
 }
@@ -748,15 +846,10 @@ func (t S2) Apply(v Token) Token {
 }
 
 func (t S3) Eval(c Context) (Token, bool) {
-	f0 := c.Eval(t.X0).(Func)
+	f0 := evalFunc(c, t.X0)
 	x2 := c.Eval(t.X2)
-	f1 := c.Eval(f0.Apply(x2)).(Func)
-	r := f1.Apply(
-		Ap2{
-			F: t.X1,
-			A: x2,
-		},
-	)
+	f1 := evalFunc(c, f0.Apply(x2))
+	r := f1.Apply(c.Ap(t.X1, x2))
 	// log.Printf("%s => %s", t, r)
 	return r, true
 }
@@ -832,8 +925,8 @@ func (t C2) Apply(v Token) Token {
 }
 
 func (t C3) Eval(c Context) (Token, bool) {
-	f0 := c.Eval(t.X0).(Func)
-	f1 := c.Eval(f0.Apply(t.X2)).(Func)
+	f0 := evalFunc(c, t.X0)
+	f1 := evalFunc(c, f0.Apply(t.X2))
 	r := f1.Apply(t.X1)
 	// log.Printf("%s => %s", t, r)
 	return r, true
@@ -910,12 +1003,7 @@ func (t B2) Apply(v Token) Token {
 }
 
 func (t B3) Eval(c Context) (Token, bool) {
-	r := c.Eval(t.X0).(Func).Apply(
-		Ap2{
-			F: t.X1,
-			A: t.X2,
-		},
-	)
+	r := evalFunc(c, t.X0).Apply(c.Ap(t.X1, t.X2))
 	return r, true
 }
 
@@ -973,8 +1061,8 @@ func (t Pwr2) Apply(v Token) Token {
 }
 
 func (t Pwr21) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Int).V
-	r := Int{V: 1 << x0}
+	x0 := evalInt(c, t.X0)
+	r := pwr2Int(x0)
 	// log.Printf("%s => %s", t, r)
 	return r, false
 }
@@ -1167,8 +1255,8 @@ func (t Cons2) Apply(v Token) Token {
 }
 
 func (t Cons3) Eval(c Context) (Token, bool) {
-	y1 := c.Eval(t.X2).(Func)
-	y2 := c.Eval(y1.Apply(t.X0)).(Func)
+	y1 := evalFunc(c, t.X2)
+	y2 := evalFunc(c, y1.Apply(t.X0))
 	r := y2.Apply(t.X1)
 	// log.Printf("%s => %s", t, r)
 	return r, true
@@ -1183,17 +1271,17 @@ func (t Cons1) Eval(c Context) (Token, bool) {
 }
 
 func (t Cons2) Eval(c Context) (Token, bool) {
-	t.X0 = c.Eval(t.X0)
-	t.X1 = c.Eval(t.X1)
+	t.X0 = evalShared(c, t.X0)
+	t.X1 = evalShared(c, t.X1)
 	return t, false
 }
 
 func (t Cons2) Car() Token {
-	return t.X0
+	return unthunk(t.X0)
 }
 
 func (t Cons2) Cdr() Token {
-	return t.X1
+	return unthunk(t.X1)
 }
 
 func (t Cons2) IsNil() bool {
@@ -1254,7 +1342,7 @@ func (t Car) Apply(v Token) Token {
 }
 
 func (t Car1) Eval(c Context) (Token, bool) {
-	y1 := c.Eval(t.X0).(Func)
+	y1 := evalFunc(c, t.X0)
 	r := y1.Apply(True{})
 	// log.Printf("%s => %s", t, r)
 	return r, true
@@ -1290,7 +1378,7 @@ func (t Cdr) Apply(v Token) Token {
 }
 
 func (t Cdr1) Eval(c Context) (Token, bool) {
-	y1 := c.Eval(t.X0).(Func)
+	y1 := evalFunc(c, t.X0)
 	r := y1.Apply(False{})
 	// log.Printf("%s => %s", t, r)
 	return r, true
@@ -1417,7 +1505,7 @@ func (t IsNil) Apply(v Token) Token {
 }
 
 func (t IsNil1) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Func)
+	x0 := evalFunc(c, t.X0)
 	r := x0.Apply(isNil{})
 	// log.Printf("%s => %s", t, r)
 	return r, true
@@ -1453,10 +1541,10 @@ type ICons interface {
 func DrawPoints(c Context, v Token) *Picture {
 	var pts []Point
 	r := NewPicture()
-	for i := c.Eval(v).(ICons); !i.IsNil(); i = i.Cdr().(ICons) {
-		p := i.Car().(ICons)
-		x := int(p.Car().(Int).V)
-		y := int(p.Cdr().(Int).V)
+	for i := evalCons(c, v); !i.IsNil(); i = asCons(i.Cdr()) {
+		p := asCons(i.Car())
+		x := int(asInt(p.Car()).Value())
+		y := int(asInt(p.Cdr()).Value())
 		pts = append(pts, Pt(x, y))
 	}
 	c.Picture().DrawPts(pts...)
@@ -1501,8 +1589,7 @@ func (t Draw1) Galaxy() string {
 type Checkerboard struct{}
 
 func (t Checkerboard) Apply(v Token) Token {
-	log.Panicf("%s not implemented", t)
-	return nil
+	panic(&Unsupported{Token: t})
 }
 
 func (t Checkerboard) Eval(c Context) (Token, bool) {
@@ -1528,8 +1615,8 @@ func (t Multipledraw) Apply(v Token) Token {
 
 func (t Multipledraw1) Eval(c Context) (Token, bool) {
 	r := NewPicture()
-	v := c.Eval(t.X0).(ICons)
-	for i := v; !i.IsNil(); i = c.Eval(i.Cdr()).(ICons) {
+	v := evalCons(c, t.X0)
+	for i := v; !i.IsNil(); i = evalCons(c, i.Cdr()) {
 		r.DrawPicture(DrawPoints(c, i.Car()))
 	}
 	return r, false
@@ -1582,9 +1669,9 @@ func (t If02) Apply(v Token) Token {
 }
 
 func (t If03) Eval(c Context) (Token, bool) {
-	x0 := c.Eval(t.X0).(Int).V
+	x0 := evalInt(c, t.X0)
 	r := t.X2
-	if x0 == 0 {
+	if x0.Sign() == 0 {
 		r = t.X1
 	}
 	// log.Printf("%s => %s", t, r)
@@ -1664,13 +1751,13 @@ func (t interactHelper2) Eval(c Context) (Token, bool) {
 	//                 then (modem(newState), multipledraw(data))
 	//                 else interact(protocol, modem(newState), send(data))
 
-	x1 := c.Eval(t.X1).(ICons)
-	flag := c.Eval(x1.Car()).(Int)
-	x11 := c.Eval(x1.Cdr()).(ICons)
+	x1 := evalCons(c, t.X1)
+	flag := evalInt(c, x1.Car())
+	x11 := evalCons(c, x1.Cdr())
 	newState := x11.Car()
-	x12 := c.Eval(x11.Cdr()).(ICons)
+	x12 := evalCons(c, x11.Cdr())
 	data := x12.Car()
-	if flag.V == 0 {
+	if flag.Sign() == 0 {
 		r := Cons2{
 			X0: newState,
 			X1: Cons2{
@@ -1684,9 +1771,7 @@ func (t interactHelper2) Eval(c Context) (Token, bool) {
 		r := Interact3{
 			X0: t.X0,
 			X1: newState,
-			X2: Send1{
-				X0: data,
-			},
+			X2: c.Ap(Send{}, data),
 		}
 		// log.Printf("%s => %s", t, r)
 		return r, true
@@ -1756,13 +1841,7 @@ func (t Interact3) Eval(c Context) (Token, bool) {
 
 	r := interactHelper2{
 		X0: t.X0,
-		X1: Ap2{
-			F: Ap2{
-				F: t.X0,
-				A: t.X1,
-			},
-			A: t.X2,
-		},
+		X1: c.Ap(c.Ap(t.X0, t.X1), t.X2),
 	}
 	// log.Printf("%s => %s", t, r)
 	return r, true
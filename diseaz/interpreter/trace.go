@@ -0,0 +1,189 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+// TraceKind is the kind of a TraceEvent.
+type TraceKind string
+
+const (
+	// TraceEval starts the evaluation of Token.
+	TraceEval TraceKind = "eval"
+	// TraceResult finishes the evaluation of Token with Result.
+	TraceResult TraceKind = "result"
+	// TraceApply reduces the redex Token to Result.
+	TraceApply TraceKind = "apply"
+	// TraceVar looks up the variable Token defined as Result.
+	TraceVar TraceKind = "var"
+	// TraceSend sends Token to the server, which answers Result.
+	TraceSend TraceKind = "send"
+)
+
+// TraceEvent is a step of evaluation reported to a Tracer.
+type TraceEvent struct {
+	Kind TraceKind
+	// Step is the number of reduction steps of the context so far.
+	Step int
+	// Level is the nesting of the evaluation.
+	Level  int
+	Token  Token
+	Result Token
+}
+
+// Tracer receives the trace events of a context. Tracers may pause
+// evaluation and may panic with an EvalError to stop it.
+type Tracer interface {
+	Trace(e *TraceEvent)
+}
+
+// TracerFunc is a Tracer function.
+type TracerFunc func(e *TraceEvent)
+
+func (f TracerFunc) Trace(e *TraceEvent) {
+	f(e)
+}
+
+// Tracers passes every event to all of ts in order.
+func Tracers(ts ...Tracer) Tracer {
+	return TracerFunc(func(e *TraceEvent) {
+		for _, t := range ts {
+			if t != nil {
+				t.Trace(e)
+			}
+		}
+	})
+}
+
+// WithTracer reports the evaluation of the context to t.
+func WithTracer(t Tracer) Option {
+	return func(c *Ctx) {
+		c.Tracer = t
+	}
+}
+
+func (c *Ctx) traceEvent(kind TraceKind, level int, t, r Token) {
+	c.Tracer.Trace(&TraceEvent{Kind: kind, Step: c.EvalCount, Level: level, Token: t, Result: r})
+}
+
+// signalToken returns the demodulated signal, or the signal itself if it is
+// not a valid modulation.
+func signalToken(signal string) Token {
+	t, err := TryDemodulateToken(signal)
+	if err != nil {
+		return Signal{S: signal}
+	}
+	return t
+}
+
+// traceRecord is a TraceEvent as written by JSONTracer.
+type traceRecord struct {
+	Kind   TraceKind
+	Step   int
+	Level  int
+	Token  string
+	Result string `json:",omitempty"`
+}
+
+// JSONTracer writes trace events to W as JSON lines. Expressions longer than
+// Width are cut unless Width is zero.
+type JSONTracer struct {
+	W     io.Writer
+	Width int
+	mu    sync.Mutex
+}
+
+func NewJSONTracer(w io.Writer, width int) *JSONTracer {
+	return &JSONTracer{W: w, Width: width}
+}
+
+func (t *JSONTracer) text(tok Token) string {
+	if tok == nil {
+		return ""
+	}
+	if t.Width == 0 {
+		return tok.Galaxy()
+	}
+	return Abbrev(tok, t.Width)
+}
+
+func (t *JSONTracer) Trace(e *TraceEvent) {
+	r := traceRecord{
+		Kind:   e.Kind,
+		Step:   e.Step,
+		Level:  e.Level,
+		Token:  t.text(e.Token),
+		Result: t.text(e.Result),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	json.NewEncoder(t.W).Encode(r)
+}
+
+// DebugCommand tells a Debugger how to go on after a pause.
+type DebugCommand int
+
+const (
+	// DebugStep pauses at the next event.
+	DebugStep DebugCommand = iota
+	// DebugOver pauses at the next event that is not nested deeper than the
+	// current one.
+	DebugOver
+	// DebugOut pauses at the result of the current evaluation.
+	DebugOut
+	// DebugContinue runs to the end without pausing.
+	DebugContinue
+	// DebugAbort stops the evaluation with ErrAborted.
+	DebugAbort
+)
+
+// ErrAborted is the error of evaluations aborted in a Debugger.
+var ErrAborted = errors.New("aborted in debugger")
+
+// Debugger is a Tracer that pauses at evaluations, reductions and results
+// and asks Prompt how to go on.
+type Debugger struct {
+	Prompt func(e *TraceEvent) DebugCommand
+
+	cmd   DebugCommand
+	level int
+}
+
+func NewDebugger(prompt func(e *TraceEvent) DebugCommand) *Debugger {
+	return &Debugger{Prompt: prompt}
+}
+
+func (d *Debugger) Trace(e *TraceEvent) {
+	switch e.Kind {
+	case TraceEval, TraceApply, TraceResult:
+	default:
+		return
+	}
+	switch d.cmd {
+	case DebugOver:
+		if e.Level > d.level {
+			return
+		}
+	case DebugOut:
+		if e.Level > d.level || e.Level == d.level && e.Kind != TraceResult {
+			return
+		}
+	case DebugContinue:
+		return
+	}
+	d.level = e.Level
+	d.cmd = d.Prompt(e)
+	switch d.cmd {
+	case DebugOut:
+		if e.Kind == TraceResult {
+			// The current evaluation is the enclosing one.
+			d.level--
+		}
+	case DebugAbort:
+		d.cmd = DebugContinue
+		panic(&Aborted{Err: ErrAborted})
+	}
+}
@@ -0,0 +1,141 @@
+package interpreter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type eventLog []TraceEvent
+
+func (l *eventLog) Trace(e *TraceEvent) {
+	*l = append(*l, *e)
+}
+
+func (l eventLog) kinds(kind TraceKind) []TraceEvent {
+	var r []TraceEvent
+	for _, e := range l {
+		if e.Kind == kind {
+			r = append(r, e)
+		}
+	}
+	return r
+}
+
+func TestTrace(t *testing.T) {
+	for _, graph := range []bool{false, true} {
+		var events eventLog
+		c := NewContext(nil, WithGraph(graph), WithTracer(&events))
+		tok := ParseString(c, ":1 = ap inc 2\nap ap add 1 :1")
+		require.Equal(t, []Token{Int{V: 4}}, tok)
+
+		require.NotEmpty(t, events)
+		first, last := events[0], events[len(events)-1]
+		assert.Equal(t, TraceEval, first.Kind, "graph %v", graph)
+		assert.Equal(t, 1, first.Level)
+		assert.Equal(t, TraceResult, last.Kind)
+		assert.Equal(t, first.Token, last.Token)
+		assert.Equal(t, Int{V: 4}, last.Result)
+
+		vars := events.kinds(TraceVar)
+		require.Len(t, vars, 1, "graph %v", graph)
+		assert.Equal(t, VarN{N: 1}, vars[0].Token)
+		// The definition is a shared thunk, evaluated by now in lazy mode.
+		assert.Contains(t, []string{"ap inc 2", "3"}, vars[0].Result.Galaxy())
+
+		applies := events.kinds(TraceApply)
+		require.NotEmpty(t, applies)
+		for _, e := range applies {
+			assert.NotNil(t, e.Result)
+			assert.GreaterOrEqual(t, e.Level, 1)
+		}
+	}
+}
+
+func TestTraceSend(t *testing.T) {
+	fake := FuncTransport(func(message string) (string, error) {
+		return ModulateToken(List(Int{V: 1})), nil
+	})
+	var events eventLog
+	c := NewContext(nil, WithTransport(fake), WithTracer(&events))
+	ParseString(c, "ap send (0)")
+	sends := events.kinds(TraceSend)
+	require.Len(t, sends, 1)
+	assert.Equal(t, "ap ap cons 0 nil", sends[0].Token.Galaxy())
+	assert.Equal(t, "ap ap cons 1 nil", sends[0].Result.Galaxy())
+}
+
+func TestJSONTracer(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewContext(nil, WithTracer(NewJSONTracer(&buf, 8)))
+	ParseString(c, "ap ap add 1 ap ap add 2 3")
+
+	var records []traceRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var r traceRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	require.NotEmpty(t, records)
+	assert.Equal(t, TraceEval, records[0].Kind)
+	assert.Equal(t, "ap ap ad...", records[0].Token)
+	assert.Equal(t, "", records[0].Result)
+	assert.Equal(t, "6", records[len(records)-1].Result)
+}
+
+func TestDebugger(t *testing.T) {
+	run := func(cmds ...DebugCommand) ([]TraceEvent, error) {
+		var paused []TraceEvent
+		d := NewDebugger(func(e *TraceEvent) DebugCommand {
+			paused = append(paused, *e)
+			if len(cmds) == 0 {
+				return DebugStep
+			}
+			cmd := cmds[0]
+			cmds = cmds[1:]
+			return cmd
+		})
+		c := NewContext(nil, WithTracer(d))
+		_, err := TryParseString(c, "ap ap add 1 ap inc 2")
+		return paused, err
+	}
+
+	all, err := run()
+	require.NoError(t, err)
+	var nested int
+	for _, e := range all {
+		if e.Level > 1 {
+			nested++
+		}
+	}
+	require.NotZero(t, nested)
+
+	paused, err := run(DebugContinue)
+	require.NoError(t, err)
+	assert.Len(t, paused, 1)
+
+	paused, err = run(DebugOver, DebugOver, DebugOver, DebugOver, DebugOver, DebugOver)
+	require.NoError(t, err)
+	assert.Len(t, paused, len(all)-nested)
+	for _, e := range paused {
+		assert.Equal(t, 1, e.Level)
+	}
+
+	paused, err = run(DebugOut)
+	require.NoError(t, err)
+	require.Len(t, paused, 2)
+	assert.Equal(t, TraceResult, paused[1].Kind)
+	assert.Equal(t, Int{V: 4}, paused[1].Result)
+
+	_, err = run(DebugStep, DebugAbort)
+	assert.True(t, errors.Is(err, ErrAborted), "err: %v", err)
+	var e *LimitError
+	assert.False(t, errors.As(err, &e), "err: %v", err)
+	assert.IsType(t, &Aborted{}, err)
+}
@@ -0,0 +1,109 @@
+package interpreter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Transport delivers modulated messages to the alien server.
+type Transport interface {
+	Send(message string) (string, error)
+}
+
+// HTTPTransport posts messages to the alien server over HTTP.
+type HTTPTransport struct {
+	URL    *url.URL
+	Client *http.Client
+}
+
+func NewHTTPTransport(serverURL *url.URL) *HTTPTransport {
+	return &HTTPTransport{
+		URL:    serverURL,
+		Client: http.DefaultClient,
+	}
+}
+
+func (t *HTTPTransport) Send(message string) (string, error) {
+	return t.SendContext(context.Background(), message)
+}
+
+func (t *HTTPTransport) SendContext(ctx context.Context, message string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL.String(), strings.NewReader(message))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	res, err := t.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP code: %d\nResponse body: %s", res.StatusCode, body)
+	}
+
+	return string(body), nil
+}
+
+// FuncTransport answers messages in-process, e.g. with a fake alien server.
+type FuncTransport func(message string) (string, error)
+
+func (f FuncTransport) Send(message string) (string, error) {
+	return f(message)
+}
+
+// FileTransport answers messages from a recorded file. Every non-empty line
+// of the file holds a modulated request and its response separated by
+// whitespace. Lines starting with `#` are comments.
+type FileTransport struct {
+	Responses map[string]string
+}
+
+func NewFileTransport(rd io.Reader) (*FileTransport, error) {
+	t := &FileTransport{Responses: make(map[string]string)}
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		fs := strings.Fields(scanner.Text())
+		if len(fs) == 0 || IsComment(fs[0]) {
+			continue
+		}
+		if len(fs) != 2 {
+			return nil, fmt.Errorf("Invalid recorded line: %#v", scanner.Text())
+		}
+		t.Responses[fs[0]] = fs[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func OpenFileTransport(fn string) (*FileTransport, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewFileTransport(f)
+}
+
+func (t *FileTransport) Send(message string) (string, error) {
+	r, found := t.Responses[message]
+	if !found {
+		return "", fmt.Errorf("No recorded response for %#v", message)
+	}
+	return r, nil
+}
@@ -0,0 +1,349 @@
+// Package optimizer searches the START allocation of a strategy. Allocations
+// are screened with the simulator and ranked by self-play in the emulator.
+package optimizer
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tarstars/icfpc2020/diseaz/emulator"
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+	"github.com/tarstars/icfpc2020/diseaz/strategy"
+)
+
+var roles = []proto.Role{proto.RoleAttacker, proto.RoleDefender}
+
+// Candidate is an allocation and its results.
+type Candidate struct {
+	Params sim.Params
+	// Score is the mean game score: 1 for a win plus the share of the total
+	// health left to own ships at the end.
+	Score float64
+	Wins  int
+	Games int
+}
+
+// Optimizer tunes the allocation of one strategy.
+type Optimizer struct {
+	Strategy string
+	// Opponents are the strategies played against. If Strategy is among
+	// them, it plays with the best allocation found so far for its role.
+	Opponents []string
+	MaxPoints int64
+	MaxHeat   int64
+	MaxTicks  int64
+	MaxClones int64
+	// Step is the initial grid step for power and cooling. It is halved
+	// while refining the best allocation.
+	Step int64
+	// Rounds is the number of times both roles are tuned in turn.
+	Rounds   int
+	Parallel int
+	Timeout  time.Duration
+	// Logf, if set, receives progress messages.
+	Logf func(format string, args ...interface{})
+
+	world  sim.World
+	server *emulator.Server
+	best   [2]*sim.Params
+}
+
+// New creates an optimizer for games in the emulator.
+func New(name string, opponents ...string) *Optimizer {
+	if len(opponents) == 0 {
+		opponents = []string{name}
+	}
+	return &Optimizer{
+		Strategy:  name,
+		Opponents: opponents,
+		MaxPoints: emulator.MaxPoints,
+		MaxHeat:   emulator.MaxHeat,
+		MaxTicks:  256,
+		MaxClones: 1,
+		Step:      8,
+		Rounds:    2,
+		Parallel:  runtime.NumCPU(),
+		Timeout:   10 * time.Second,
+		world:     sim.DefaultWorld,
+	}
+}
+
+func (o *Optimizer) logf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
+func (o *Optimizer) info(role proto.Role) *proto.GameStaticInfo {
+	return &proto.GameStaticInfo{
+		MaxTicks:    o.MaxTicks,
+		Role:        role,
+		Constraints: proto.Constraints{MaxPoints: o.MaxPoints, MaxHeat: o.MaxHeat},
+		World:       o.world,
+	}
+}
+
+// MinFuel is the fuel the autopilot spends to keep a ship of role on orbit
+// for the whole game.
+func (o *Optimizer) MinFuel(role proto.Role) int64 {
+	pilot := strategy.NewAutopilot(o.world)
+	s := sim.Ship{Position: emulator.StartPosition(role), Params: sim.Params{Fuel: o.MaxPoints}, MaxHeat: o.MaxPoints * sim.ThrustHeat}
+	var fuel int64
+	for tick := int64(0); tick < o.MaxTicks && !s.Destroyed; tick++ {
+		thrust := pilot.Thrust(s, int(o.MaxTicks-tick))
+		fuel += thrust.Norm()
+		s = o.world.Step(s, thrust)
+	}
+	return fuel
+}
+
+// Useful reports whether p is an allocation worth playing: it fits the budget,
+// has fuel for minFuel, and neither power nor cooling exceeds what the heat
+// limit lets the ship use.
+func (o *Optimizer) Useful(p sim.Params, minFuel int64) bool {
+	if strategy.CheckParams(p, o.info(proto.RoleAttacker)) != nil {
+		return false
+	}
+	if p.Fuel < minFuel {
+		return false
+	}
+	if p.Power > o.MaxHeat+p.Cooling {
+		return false
+	}
+	return p.Cooling <= p.Power+sim.MaxThrust*sim.ThrustHeat
+}
+
+// allocation returns the allocation with power, cooling and clones that
+// spends the rest of the budget on fuel.
+func (o *Optimizer) allocation(power, cooling, clones int64) sim.Params {
+	p := sim.Params{Power: power, Cooling: cooling, Clones: clones}
+	p.Fuel = o.MaxPoints - p.Cost()
+	return p
+}
+
+// Grid returns the useful allocations with power and cooling in multiples of
+// step.
+func (o *Optimizer) Grid(step, minFuel int64) []sim.Params {
+	var ps []sim.Params
+	for clones := int64(1); clones <= o.MaxClones; clones++ {
+		for cooling := int64(0); ; cooling += step {
+			if o.allocation(0, cooling, clones).Fuel < minFuel {
+				break
+			}
+			for power := int64(0); ; power += step {
+				p := o.allocation(power, cooling, clones)
+				if p.Fuel < minFuel {
+					break
+				}
+				if o.Useful(p, minFuel) {
+					ps = append(ps, p)
+				}
+			}
+		}
+	}
+	return ps
+}
+
+// neighbours returns the useful allocations step away from p in power and
+// cooling.
+func (o *Optimizer) neighbours(p sim.Params, step, minFuel int64) []sim.Params {
+	var ps []sim.Params
+	for _, dp := range []int64{-step, 0, step} {
+		for _, dc := range []int64{-step, 0, step} {
+			if dp == 0 && dc == 0 {
+				continue
+			}
+			n := o.allocation(p.Power+dp, p.Cooling+dc, p.Clones)
+			if o.Useful(n, minFuel) {
+				ps = append(ps, n)
+			}
+		}
+	}
+	return ps
+}
+
+func (o *Optimizer) opponent(name string, role proto.Role) (strategy.Strategy, error) {
+	st, err := strategy.New(name)
+	if err != nil {
+		return nil, err
+	}
+	if p := o.best[role]; name == o.Strategy && p != nil {
+		st = strategy.WithParams(st, *p)
+	}
+	return st, nil
+}
+
+// health returns the total health of the ships of role.
+func health(state *proto.GameState, role proto.Role) int64 {
+	var h int64
+	for _, s := range strategy.ShipsOf(role, state) {
+		h += s.Ship.Health()
+	}
+	return h
+}
+
+// play plays c as role against the opponent and returns the game score.
+func (o *Optimizer) play(c *Candidate, role proto.Role, opponent string) (float64, bool, error) {
+	st, err := strategy.New(o.Strategy)
+	if err != nil {
+		return 0, false, err
+	}
+	me := strategy.WithParams(st, c.Params)
+	enemy, err := o.opponent(opponent, role.Opponent())
+	if err != nil {
+		return 0, false, err
+	}
+	players := [2]strategy.Strategy{}
+	players[role], players[role.Opponent()] = me, enemy
+
+	var last *proto.GameState
+	winner, _, err := o.server.PlayMatch(players[proto.RoleAttacker], players[proto.RoleDefender],
+		func(r proto.Role, name string, resp gx.Token, gr *proto.GameResponse) {
+			if r == role && gr != nil && gr.State != nil {
+				last = gr.State
+			}
+		})
+	if err != nil {
+		return 0, false, fmt.Errorf("%+v as %s against %s: %w", c.Params, role, opponent, err)
+	}
+
+	won := winner == role
+	score := 0.5
+	mine, theirs := health(last, role), health(last, role.Opponent())
+	if mine+theirs > 0 {
+		score = float64(mine) / float64(mine+theirs)
+	}
+	if won {
+		score++
+	}
+	return score, won, nil
+}
+
+// evaluate plays every candidate against every opponent.
+func (o *Optimizer) evaluate(cs []*Candidate, role proto.Role) error {
+	type job struct {
+		c        *Candidate
+		opponent string
+	}
+	jobs := make(chan job)
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < o.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				score, won, err := o.play(j.c, role, j.opponent)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				j.c.Score = (j.c.Score*float64(j.c.Games) + score) / float64(j.c.Games+1)
+				j.c.Games++
+				if won {
+					j.c.Wins++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, c := range cs {
+		for _, opp := range o.Opponents {
+			jobs <- job{c: c, opponent: opp}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// Search returns the candidates for role, best first. It plays a coarse grid
+// and the strategy's own allocation, then refines around the best candidate
+// with ever smaller steps.
+func (o *Optimizer) Search(role proto.Role) ([]*Candidate, error) {
+	if o.server == nil {
+		o.server = emulator.NewServer(o.MaxTicks, o.Timeout)
+		o.server.Quiet = true
+	}
+	st, err := strategy.New(o.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	minFuel := o.MinFuel(role)
+	o.logf("%s needs %d fuel to stay on orbit", role, minFuel)
+
+	seen := make(map[sim.Params]bool)
+	var all []*Candidate
+	add := func(ps []sim.Params) []*Candidate {
+		var cs []*Candidate
+		for _, p := range ps {
+			if !seen[p] {
+				seen[p] = true
+				cs = append(cs, &Candidate{Params: p})
+			}
+		}
+		all = append(all, cs...)
+		return cs
+	}
+
+	batch := add(append(o.Grid(o.Step, minFuel), st.Start(o.info(role))))
+	for step := o.Step; ; step /= 2 {
+		o.logf("%s: playing %d allocations", role, len(batch))
+		if err := o.evaluate(batch, role); err != nil {
+			return nil, err
+		}
+		sortCandidates(all)
+		if step <= 1 {
+			break
+		}
+		batch = add(o.neighbours(all[0].Params, step/2, minFuel))
+	}
+	return all, nil
+}
+
+func sortCandidates(cs []*Candidate) {
+	sort.SliceStable(cs, func(i, j int) bool {
+		if cs[i].Score != cs[j].Score {
+			return cs[i].Score > cs[j].Score
+		}
+		return cs[i].Params.Fuel > cs[j].Params.Fuel
+	})
+}
+
+// Optimize tunes both roles in turn for Rounds rounds, each playing against
+// the best allocation found for the other, and returns the best ones.
+func (o *Optimizer) Optimize() (*strategy.ParamsConfig, error) {
+	for _, name := range append([]string{o.Strategy}, o.Opponents...) {
+		if _, err := strategy.New(name); err != nil {
+			return nil, err
+		}
+	}
+	for round := 0; round < o.Rounds; round++ {
+		for _, role := range roles {
+			cs, err := o.Search(role)
+			if err != nil {
+				return nil, err
+			}
+			best := cs[0]
+			o.best[role] = &best.Params
+			o.logf("Round %d: %s %+v, score %.3f, won %d of %d",
+				round+1, role, best.Params, best.Score, best.Wins, best.Games)
+		}
+	}
+
+	c := &strategy.ParamsConfig{Strategy: o.Strategy, MaxPoints: o.MaxPoints}
+	for _, role := range roles {
+		if p := o.best[role]; p != nil {
+			c.Set(role, *p)
+		}
+	}
+	return c, nil
+}
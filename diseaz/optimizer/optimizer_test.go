@@ -0,0 +1,51 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func TestGrid(t *testing.T) {
+	o := New("orbit")
+	minFuel := o.MinFuel(proto.RoleAttacker)
+	assert.True(t, minFuel > 0 && minFuel < o.MaxPoints, minFuel)
+
+	ps := o.Grid(16, minFuel)
+	require.NotEmpty(t, ps)
+	assert.Contains(t, ps, sim.Params{Fuel: 446, Power: 16, Cooling: 0, Clones: 1})
+	for _, p := range ps {
+		assert.Equal(t, o.MaxPoints, p.Cost(), p)
+		assert.True(t, o.Useful(p, minFuel), p)
+	}
+	// Power that cannot be fired within the heat limit is wasted.
+	assert.False(t, o.Useful(o.allocation(80, 0, 1), minFuel))
+	// So is cooling beyond the heat of shots and thrust.
+	assert.False(t, o.Useful(o.allocation(0, 20, 1), minFuel))
+}
+
+func TestOptimize(t *testing.T) {
+	o := New("hunter", "hunter", "orbit")
+	o.MaxTicks = 32
+	o.Step = 32
+	o.Rounds = 1
+
+	c, err := o.Optimize()
+	require.NoError(t, err)
+	assert.Equal(t, "hunter", c.Strategy)
+	for _, role := range roles {
+		p := c.For(role)
+		require.NotNil(t, p, role)
+		assert.True(t, p.Cost() <= o.MaxPoints, p)
+		assert.True(t, p.Clones >= 1, p)
+	}
+}
+
+func TestUnknownStrategy(t *testing.T) {
+	_, err := New("no-such-strategy").Optimize()
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+// Package proto encodes and decodes the requests of the alien battle game.
+package proto
+
+import (
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+type CommandType int64
+
+const (
+	CommandAccelerate CommandType = 0
+	CommandDetonate   CommandType = 1
+	CommandShoot      CommandType = 2
+	CommandFork       CommandType = 3
+)
+
+// Command is a ship command sent with the COMMANDS request.
+type Command interface {
+	Token() gx.Token
+}
+
+func VecToken(v sim.Vec) gx.Token {
+	return gx.Pair(gx.Int{V: v.X}, gx.Int{V: v.Y})
+}
+
+func ParamsToken(p sim.Params) gx.Token {
+	return gx.List(gx.Ints(p.Fuel, p.Power, p.Cooling, p.Clones)...)
+}
+
+// Accelerate thrusts the ship. The velocity changes by -Vec.
+type Accelerate struct {
+	ShipID int64
+	Vec    sim.Vec
+}
+
+func (c Accelerate) Token() gx.Token {
+	return gx.List(gx.Int{V: int64(CommandAccelerate)}, gx.Int{V: c.ShipID}, VecToken(c.Vec))
+}
+
+type Detonate struct {
+	ShipID int64
+}
+
+func (c Detonate) Token() gx.Token {
+	return gx.List(gx.Int{V: int64(CommandDetonate)}, gx.Int{V: c.ShipID})
+}
+
+// Shoot fires a laser at Target.
+type Shoot struct {
+	ShipID int64
+	Target sim.Vec
+	Power  int64
+}
+
+func (c Shoot) Token() gx.Token {
+	return gx.List(
+		gx.Int{V: int64(CommandShoot)},
+		gx.Int{V: c.ShipID},
+		VecToken(c.Target),
+		gx.Int{V: c.Power},
+	)
+}
+
+// Fork splits off a new ship with Params taken from the parent.
+type Fork struct {
+	ShipID int64
+	Params sim.Params
+}
+
+func (c Fork) Token() gx.Token {
+	return gx.List(gx.Int{V: int64(CommandFork)}, gx.Int{V: c.ShipID}, ParamsToken(c.Params))
+}
@@ -0,0 +1,147 @@
+package proto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func parse(t *testing.T, program string) gx.Token {
+	c := gx.NewContext(nil)
+	ts, err := gx.TryParseString(c, program)
+	require.NoError(t, err)
+	require.Len(t, ts, 1)
+	return ts[0]
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		cmd  Command
+		text string
+	}{
+		{Accelerate{ShipID: 1, Vec: sim.Vec{X: -1, Y: 2}}, "(0, 1, ap ap vec -1 2)"},
+		{Detonate{ShipID: 0}, "(1, 0)"},
+		{Shoot{ShipID: 1, Target: sim.Vec{X: 5, Y: 6}, Power: 64}, "(2, 1, ap ap vec 5 6, 64)"},
+		{Fork{ShipID: 2, Params: sim.Params{Fuel: 10, Power: 1, Cooling: 2, Clones: 1}}, "(3, 2, (10, 1, 2, 1))"},
+	}
+	for _, tt := range tests {
+		expected := parse(t, tt.text)
+		assert.Equal(t, gx.ModulateToken(expected), gx.ModulateToken(tt.cmd.Token()), tt.text)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	tests := []struct {
+		req  Request
+		text string
+	}{
+		{Create{}, "(1, 0)"},
+		{Join{PlayerKey: 1000}, "(2, 1000, nil)"},
+		{Start{PlayerKey: 1001, Params: sim.Params{Fuel: 1, Power: 1, Cooling: 1, Clones: 1}}, "(3, 1001, (1, 1, 1, 1))"},
+		{Commands{PlayerKey: 1000}, "(4, 1000, nil)"},
+		{
+			Commands{PlayerKey: 1000, Commands: []Command{Accelerate{ShipID: 0}, Detonate{ShipID: 1}}},
+			"(4, 1000, ((0, 0, ap ap vec 0 0), (1, 1)))",
+		},
+	}
+	for _, tt := range tests {
+		expected := parse(t, tt.text)
+		assert.Equal(t, gx.ModulateToken(expected), Modulate(tt.req), tt.text)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got string
+	c := gx.NewContext(nil, gx.WithTransport(gx.FuncTransport(func(m string) (string, error) {
+		got = m
+		return gx.ModulateToken(gx.List(gx.Int{V: 0})), nil
+	})))
+	r, err := Send(c, Join{PlayerKey: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, Modulate(Join{PlayerKey: 1000}), got)
+	assert.Equal(t, "ap ap cons 0 nil", r.Galaxy())
+}
+
+const startedResponse = "(1, 1, (256, 0, (512, 1, 64), (16, 128), (1, 2, 3, 4)), (5, (16, 128), (" +
+	"((1, 0, ap ap vec 16 -48, ap ap vec 1 2, (10, 2, 3, 1), 8, 64, 1), ((0, ap ap vec -1 0)))," +
+	"((0, 1, ap ap vec -16 48, ap ap vec 0 0, (0, 1, 1, 1), 0, 64, 1), ((2, ap ap vec 16 -48, 1, 3, 4)))" +
+	")))"
+
+func TestDecodeGameResponse(t *testing.T) {
+	tok := parse(t, startedResponse)
+	gr, err := DecodeGameResponse(tok)
+	require.NoError(t, err)
+
+	assert.Equal(t, GameStarted, gr.Stage)
+	assert.Equal(t, &GameStaticInfo{
+		MaxTicks:    256,
+		Role:        RoleAttacker,
+		Constraints: Constraints{MaxPoints: 512, Reserved: 1, MaxHeat: 64},
+		World:       sim.World{PlanetRadius: 16, ArenaRadius: 128},
+		Enemy:       &sim.Params{Fuel: 1, Power: 2, Cooling: 3, Clones: 4},
+	}, gr.StaticInfo)
+
+	require.Len(t, gr.State.Ships, 2)
+	assert.Equal(t, int64(5), gr.State.Tick)
+	ss := gr.State.Ships[0]
+	assert.Equal(t, ShipState{
+		Role: RoleDefender,
+		ID:   0,
+		Ship: sim.Ship{
+			Position: sim.Vec{X: 16, Y: -48},
+			Velocity: sim.Vec{X: 1, Y: 2},
+			Params:   sim.Params{Fuel: 10, Power: 2, Cooling: 3, Clones: 1},
+			Heat:     8,
+			MaxHeat:  64,
+		},
+		MaxThrust: 1,
+	}, ss.Ship)
+	assert.Equal(t, []AppliedCommand{{Type: CommandAccelerate, Vec: sim.Vec{X: -1}}}, ss.Commands)
+	assert.Equal(t, []AppliedCommand{
+		{Type: CommandShoot, Vec: sim.Vec{X: 16, Y: -48}, Values: []int64{1, 3, 4}},
+	}, gr.State.Ships[1].Commands)
+
+	assert.Equal(t, gx.ModulateToken(tok), gx.ModulateToken(gr.Token()))
+}
+
+func TestDecodeGameResponsePending(t *testing.T) {
+	tok := parse(t, "(1, 0, (256, 1, (512, 1, 64), (16, 128), nil), nil)")
+	gr, err := DecodeGameResponse(tok)
+	require.NoError(t, err)
+	assert.Equal(t, GamePending, gr.Stage)
+	assert.Nil(t, gr.StaticInfo.Enemy)
+	assert.Nil(t, gr.State)
+	assert.Equal(t, gx.ModulateToken(tok), gx.ModulateToken(gr.Token()))
+}
+
+func TestDecodeGameResponseErrors(t *testing.T) {
+	_, err := DecodeGameResponse(parse(t, "(0)"))
+	assert.Equal(t, ErrRejected, err)
+
+	tests := []struct {
+		text string
+		msg  string
+	}{
+		{"5", "Bad GameResponse: expected list, got 5"},
+		{"(1, 1, nil)", "Bad GameResponse: expected 4 items, got ap ap cons 1 ap ap cons 1 ap ap cons nil nil"},
+		{
+			"(1, 1, nil, (0, (16, 128), (((1, 0, ap ap vec 1 nil, ap ap vec 0 0, (1, 1, 1, 1), 0, 64, 1), nil))))",
+			"Bad GameResponse.State.Ships[0].Ship.Position.Y: expected int, got nil",
+		},
+		{
+			"(1, 1, nil, (0, (16, 128), (((1, 0, ap ap vec 1 1, ap ap vec 0 0, (1, 1, 1, 1), 0, 64, 1), ((7))))))",
+			"Bad GameResponse.State.Ships[0].Commands[0].Type: unknown command, got 7",
+		},
+	}
+	for _, tt := range tests {
+		_, err := DecodeGameResponse(parse(t, tt.text))
+		var de *DecodeError
+		require.True(t, errors.As(err, &de), tt.text)
+		assert.Equal(t, tt.msg, err.Error())
+	}
+}
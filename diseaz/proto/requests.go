@@ -0,0 +1,69 @@
+package proto
+
+import (
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+type RequestType int64
+
+const (
+	RequestCreate   RequestType = 1
+	RequestJoin     RequestType = 2
+	RequestStart    RequestType = 3
+	RequestCommands RequestType = 4
+)
+
+// Request is a message sent to the alien server.
+type Request interface {
+	Token() gx.Token
+}
+
+// Modulate encodes r as a signal for the alien server.
+func Modulate(r Request) string {
+	return gx.ModulateToken(r.Token())
+}
+
+// Send sends r through c and returns the demodulated response.
+func Send(c *gx.Ctx, r Request) (gx.Token, error) {
+	return c.TrySendToken(r.Token())
+}
+
+// Create asks for a new game and returns the player keys.
+type Create struct{}
+
+func (r Create) Token() gx.Token {
+	return gx.List(gx.Int{V: int64(RequestCreate)}, gx.Int{V: 0})
+}
+
+type Join struct {
+	PlayerKey int64
+}
+
+func (r Join) Token() gx.Token {
+	return gx.List(gx.Int{V: int64(RequestJoin)}, gx.Int{V: r.PlayerKey}, gx.Nil{})
+}
+
+// Start chooses the initial parameters of the ship.
+type Start struct {
+	PlayerKey int64
+	Params    sim.Params
+}
+
+func (r Start) Token() gx.Token {
+	return gx.List(gx.Int{V: int64(RequestStart)}, gx.Int{V: r.PlayerKey}, ParamsToken(r.Params))
+}
+
+// Commands sends the commands of one tick.
+type Commands struct {
+	PlayerKey int64
+	Commands  []Command
+}
+
+func (r Commands) Token() gx.Token {
+	cmds := make([]gx.Token, len(r.Commands))
+	for i, c := range r.Commands {
+		cmds[i] = c.Token()
+	}
+	return gx.List(gx.Int{V: int64(RequestCommands)}, gx.Int{V: r.PlayerKey}, gx.List(cmds...))
+}
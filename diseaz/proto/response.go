@@ -0,0 +1,395 @@
+package proto
+
+import (
+	"errors"
+	"fmt"
+
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+type Role int64
+
+const (
+	RoleAttacker Role = 0
+	RoleDefender Role = 1
+)
+
+// Opponent returns the other role.
+func (r Role) Opponent() Role {
+	return 1 - r
+}
+
+type GameStage int64
+
+const (
+	GamePending  GameStage = 0
+	GameStarted  GameStage = 1
+	GameFinished GameStage = 2
+)
+
+// ErrRejected is returned for the (0) response to an invalid request.
+var ErrRejected = errors.New("Request rejected by the server")
+
+// DecodeError describes the part of a response that does not match the model.
+type DecodeError struct {
+	Path   string
+	Reason string
+	Got    gx.Token
+}
+
+func (e *DecodeError) Error() string {
+	got := "nothing"
+	if e.Got != nil {
+		got = e.Got.Galaxy()
+	}
+	return fmt.Sprintf("Bad %s: %s, got %s", e.Path, e.Reason, got)
+}
+
+// GameResponse is the response to JOIN, START and COMMANDS:
+// (1, stage, staticInfo, state).
+type GameResponse struct {
+	Stage      GameStage
+	StaticInfo *GameStaticInfo
+	State      *GameState
+}
+
+// GameStaticInfo does not change during the game:
+// (maxTicks, role, constraints, world, enemyParams).
+type GameStaticInfo struct {
+	MaxTicks    int64
+	Role        Role
+	Constraints Constraints
+	World       sim.World
+	// Enemy is the initial parameters of the enemy ship. The defender's are
+	// known to the attacker once the game has started.
+	Enemy *sim.Params
+}
+
+// Constraints limits the ship parameters chosen at START.
+type Constraints struct {
+	MaxPoints int64
+	// Reserved is sent by the server with unknown meaning.
+	Reserved int64
+	MaxHeat  int64
+}
+
+// GameState is the state of a started game: (tick, world, shipsAndCommands).
+type GameState struct {
+	Tick  int64
+	World sim.World
+	Ships []*ShipAndCommands
+}
+
+// ShipAndCommands is a ship with the commands it applied in the last tick.
+type ShipAndCommands struct {
+	Ship     ShipState
+	Commands []AppliedCommand
+}
+
+// ShipState is (role, id, position, velocity, params, heat, maxHeat,
+// maxThrust).
+type ShipState struct {
+	Role Role
+	ID   int64
+	sim.Ship
+	MaxThrust int64
+}
+
+// Predict returns the ship state after n ticks without thrust.
+func (ss ShipState) Predict(w sim.World, n int) sim.Ship {
+	return w.Predict(ss.Ship, n)
+}
+
+// AppliedCommand is a command as reported back by the server: the type
+// followed by its arguments, e.g. (2, target, power, damage, heat) for a shot.
+type AppliedCommand struct {
+	Type CommandType
+	// Vec is the thrust of accelerate or the target of shoot.
+	Vec sim.Vec
+	// Params is the new ship of fork.
+	Params sim.Params
+	// Values is the remaining numeric arguments.
+	Values []int64
+}
+
+func (gr *GameResponse) Token() gx.Token {
+	var static, state gx.Token = gx.Nil{}, gx.Nil{}
+	if gr.StaticInfo != nil {
+		static = gr.StaticInfo.Token()
+	}
+	if gr.State != nil {
+		state = gr.State.Token()
+	}
+	return gx.List(gx.Int{V: 1}, gx.Int{V: int64(gr.Stage)}, static, state)
+}
+
+func (gi *GameStaticInfo) Token() gx.Token {
+	var enemy gx.Token = gx.Nil{}
+	if gi.Enemy != nil {
+		enemy = ParamsToken(*gi.Enemy)
+	}
+	return gx.List(
+		gx.Int{V: gi.MaxTicks},
+		gx.Int{V: int64(gi.Role)},
+		gx.List(gx.Ints(gi.Constraints.MaxPoints, gi.Constraints.Reserved, gi.Constraints.MaxHeat)...),
+		WorldToken(gi.World),
+		enemy,
+	)
+}
+
+func WorldToken(w sim.World) gx.Token {
+	return gx.List(gx.Ints(w.PlanetRadius, w.ArenaRadius)...)
+}
+
+func (gs *GameState) Token() gx.Token {
+	ships := make([]gx.Token, len(gs.Ships))
+	for i, s := range gs.Ships {
+		ships[i] = s.Token()
+	}
+	return gx.List(gx.Int{V: gs.Tick}, WorldToken(gs.World), gx.List(ships...))
+}
+
+func (sc *ShipAndCommands) Token() gx.Token {
+	cmds := make([]gx.Token, len(sc.Commands))
+	for i, c := range sc.Commands {
+		cmds[i] = c.Token()
+	}
+	return gx.List(sc.Ship.Token(), gx.List(cmds...))
+}
+
+func (ss *ShipState) Token() gx.Token {
+	return gx.List(
+		gx.Int{V: int64(ss.Role)},
+		gx.Int{V: ss.ID},
+		VecToken(ss.Position),
+		VecToken(ss.Velocity),
+		ParamsToken(ss.Params),
+		gx.Int{V: ss.Heat},
+		gx.Int{V: ss.MaxHeat},
+		gx.Int{V: ss.MaxThrust},
+	)
+}
+
+func (ac AppliedCommand) Token() gx.Token {
+	ts := []gx.Token{gx.Int{V: int64(ac.Type)}}
+	switch ac.Type {
+	case CommandAccelerate, CommandShoot:
+		ts = append(ts, VecToken(ac.Vec))
+	case CommandFork:
+		ts = append(ts, ParamsToken(ac.Params))
+	}
+	ts = append(ts, gx.Ints(ac.Values...)...)
+	return gx.List(ts...)
+}
+
+// DecodeGameResponse decodes the response to JOIN, START or COMMANDS.
+func DecodeGameResponse(t gx.Token) (*GameResponse, error) {
+	d := decoder{}
+	gr := d.gameResponse("GameResponse", t)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return gr, nil
+}
+
+// decoder keeps the first error, so the decoding functions may run to the
+// end on bad input and be checked once.
+type decoder struct {
+	err error
+}
+
+func (d *decoder) fail(path, reason string, got gx.Token) {
+	if d.err == nil {
+		d.err = &DecodeError{Path: path, Reason: reason, Got: got}
+	}
+}
+
+// list returns the items of a list of n items, or of any length if n < 0.
+func (d *decoder) list(path string, t gx.Token, n int) []gx.Token {
+	if d.err != nil {
+		return nil
+	}
+	ts, err := gx.ListItems(t)
+	if err != nil {
+		d.fail(path, "expected list", t)
+		return nil
+	}
+	if n >= 0 && len(ts) != n {
+		d.fail(path, fmt.Sprintf("expected %d items", n), t)
+		return nil
+	}
+	return ts
+}
+
+func (d *decoder) int(path string, t gx.Token) int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, ok := t.(gx.Int)
+	if !ok || v.IsBig() {
+		d.fail(path, "expected int", t)
+		return 0
+	}
+	return v.V
+}
+
+func (d *decoder) ints(path string, t gx.Token, n int) []int64 {
+	ts := d.list(path, t, n)
+	vs := make([]int64, len(ts))
+	for i, it := range ts {
+		vs[i] = d.int(fmt.Sprintf("%s[%d]", path, i), it)
+	}
+	return vs
+}
+
+func (d *decoder) vec(path string, t gx.Token) sim.Vec {
+	if d.err != nil {
+		return sim.Vec{}
+	}
+	c, ok := t.(gx.ICons)
+	if !ok || c.IsNil() {
+		d.fail(path, "expected vector", t)
+		return sim.Vec{}
+	}
+	return sim.Vec{X: d.int(path+".X", c.Car()), Y: d.int(path+".Y", c.Cdr())}
+}
+
+func (d *decoder) params(path string, t gx.Token) sim.Params {
+	vs := d.ints(path, t, 4)
+	if len(vs) != 4 {
+		return sim.Params{}
+	}
+	return sim.Params{Fuel: vs[0], Power: vs[1], Cooling: vs[2], Clones: vs[3]}
+}
+
+func (d *decoder) world(path string, t gx.Token) sim.World {
+	vs := d.ints(path, t, 2)
+	if len(vs) != 2 {
+		return sim.World{}
+	}
+	return sim.World{PlanetRadius: vs[0], ArenaRadius: vs[1]}
+}
+
+func isNil(t gx.Token) bool {
+	c, ok := t.(gx.ICons)
+	return ok && c.IsNil()
+}
+
+func (d *decoder) gameResponse(path string, t gx.Token) *GameResponse {
+	ts := d.list(path, t, -1)
+	if len(ts) == 0 {
+		d.fail(path, "expected status", t)
+		return nil
+	}
+	if d.int(path+".Status", ts[0]) == 0 && d.err == nil {
+		d.err = ErrRejected
+		return nil
+	}
+	if len(ts) != 4 {
+		d.fail(path, "expected 4 items", t)
+		return nil
+	}
+
+	gr := &GameResponse{Stage: GameStage(d.int(path+".Stage", ts[1]))}
+	if !isNil(ts[2]) {
+		gr.StaticInfo = d.gameStaticInfo(path+".StaticInfo", ts[2])
+	}
+	if !isNil(ts[3]) {
+		gr.State = d.gameState(path+".State", ts[3])
+	}
+	return gr
+}
+
+func (d *decoder) gameStaticInfo(path string, t gx.Token) *GameStaticInfo {
+	ts := d.list(path, t, 5)
+	if len(ts) != 5 {
+		return nil
+	}
+	gi := &GameStaticInfo{
+		MaxTicks: d.int(path+".MaxTicks", ts[0]),
+		Role:     Role(d.int(path+".Role", ts[1])),
+		World:    d.world(path+".World", ts[3]),
+	}
+	if cs := d.ints(path+".Constraints", ts[2], 3); len(cs) == 3 {
+		gi.Constraints = Constraints{MaxPoints: cs[0], Reserved: cs[1], MaxHeat: cs[2]}
+	}
+	if !isNil(ts[4]) {
+		p := d.params(path+".Enemy", ts[4])
+		gi.Enemy = &p
+	}
+	return gi
+}
+
+func (d *decoder) gameState(path string, t gx.Token) *GameState {
+	ts := d.list(path, t, 3)
+	if len(ts) != 3 {
+		return nil
+	}
+	gs := &GameState{
+		Tick:  d.int(path+".Tick", ts[0]),
+		World: d.world(path+".World", ts[1]),
+	}
+	for i, s := range d.list(path+".Ships", ts[2], -1) {
+		gs.Ships = append(gs.Ships, d.shipAndCommands(fmt.Sprintf("%s.Ships[%d]", path, i), s))
+	}
+	return gs
+}
+
+func (d *decoder) shipAndCommands(path string, t gx.Token) *ShipAndCommands {
+	ts := d.list(path, t, 2)
+	if len(ts) != 2 {
+		return nil
+	}
+	sc := &ShipAndCommands{Ship: d.shipState(path+".Ship", ts[0])}
+	for i, c := range d.list(path+".Commands", ts[1], -1) {
+		sc.Commands = append(sc.Commands, d.appliedCommand(fmt.Sprintf("%s.Commands[%d]", path, i), c))
+	}
+	return sc
+}
+
+func (d *decoder) shipState(path string, t gx.Token) (ss ShipState) {
+	ts := d.list(path, t, 8)
+	if len(ts) != 8 {
+		return ss
+	}
+	ss.Role = Role(d.int(path+".Role", ts[0]))
+	ss.ID = d.int(path+".ID", ts[1])
+	ss.Position = d.vec(path+".Position", ts[2])
+	ss.Velocity = d.vec(path+".Velocity", ts[3])
+	ss.Params = d.params(path+".Params", ts[4])
+	ss.Heat = d.int(path+".Heat", ts[5])
+	ss.MaxHeat = d.int(path+".MaxHeat", ts[6])
+	ss.MaxThrust = d.int(path+".MaxThrust", ts[7])
+	return ss
+}
+
+func (d *decoder) appliedCommand(path string, t gx.Token) (ac AppliedCommand) {
+	ts := d.list(path, t, -1)
+	if len(ts) == 0 {
+		d.fail(path, "expected command type", t)
+		return ac
+	}
+	ac.Type = CommandType(d.int(path+".Type", ts[0]))
+	rest := ts[1:]
+	switch ac.Type {
+	case CommandAccelerate, CommandShoot, CommandFork:
+		if len(rest) == 0 {
+			d.fail(path, "expected command argument", t)
+			return ac
+		}
+		if ac.Type == CommandFork {
+			ac.Params = d.params(path+".Params", rest[0])
+		} else {
+			ac.Vec = d.vec(path+".Vec", rest[0])
+		}
+		rest = rest[1:]
+	case CommandDetonate:
+	default:
+		d.fail(path+".Type", "unknown command", ts[0])
+	}
+	for i, v := range rest {
+		ac.Values = append(ac.Values, d.int(fmt.Sprintf("%s.Values[%d]", path, i), v))
+	}
+	return ac
+}
@@ -0,0 +1,256 @@
+package replay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strings"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+var (
+	colorBackground = color.RGBA{0, 0, 0, 0xff}
+	colorArena      = color.RGBA{0x40, 0x40, 0x40, 0xff}
+	colorPlanet     = color.RGBA{0x80, 0x80, 0x80, 0xff}
+	colorAttacker   = color.RGBA{0xff, 0x40, 0x40, 0xff}
+	colorDefender   = color.RGBA{0x40, 0x80, 0xff, 0xff}
+	colorTrailA     = color.RGBA{0x80, 0x20, 0x20, 0xff}
+	colorTrailD     = color.RGBA{0x20, 0x40, 0x80, 0xff}
+	colorShot       = color.RGBA{0xff, 0xff, 0x00, 0xff}
+	colorExplosion  = color.RGBA{0xff, 0x90, 0x00, 0xff}
+
+	palette = color.Palette{
+		colorBackground, colorArena, colorPlanet, colorAttacker, colorDefender,
+		colorTrailA, colorTrailD, colorShot, colorExplosion,
+	}
+)
+
+func shipColor(role proto.Role) color.RGBA {
+	if role == proto.RoleAttacker {
+		return colorAttacker
+	}
+	return colorDefender
+}
+
+func trailColor(role proto.Role) color.RGBA {
+	if role == proto.RoleAttacker {
+		return colorTrailA
+	}
+	return colorTrailD
+}
+
+func rgb(c color.RGBA) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
+}
+
+// explosionRadius is the size of explosion marks in game cells.
+const explosionRadius = 4
+
+// RenderSVG writes the game as an SVG timeline: the full trajectories with
+// shots and explosions, and the ships animated along them with tickDuration
+// seconds per tick.
+func RenderSVG(w io.Writer, r *Replay, tickDuration float64) error {
+	world := r.World()
+	tracks, events := r.Analyze()
+	ar := world.ArenaRadius + 2
+	pr := world.PlanetRadius
+	dur := float64(len(r.Ticks)) * tickDuration
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%d %d %d %d">`, -ar, -ar, 2*ar, 2*ar)
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, -ar, -ar, 2*ar, 2*ar, rgb(colorBackground))
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="%s"/>`,
+		-world.ArenaRadius, -world.ArenaRadius, 2*world.ArenaRadius, 2*world.ArenaRadius, rgb(colorArena))
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, -pr, -pr, 2*pr+1, 2*pr+1, rgb(colorPlanet))
+
+	for _, t := range tracks {
+		var pts []string
+		for _, p := range t.Positions {
+			pts = append(pts, fmt.Sprintf("%d,%d", p.X, p.Y))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="0.5"/>`,
+			strings.Join(pts, " "), rgb(trailColor(t.Ship.Role)))
+	}
+	for _, e := range events {
+		begin := float64(e.Tick) * tickDuration
+		if e.Explosion {
+			fmt.Fprintf(&b, `<circle cx="%d" cy="%d" r="%d" fill="%s" fill-opacity="0.6">`,
+				e.Pos.X, e.Pos.Y, explosionRadius, rgb(colorExplosion))
+		} else {
+			fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="0.5" stroke-opacity="0.6">`,
+				e.Pos.X, e.Pos.Y, e.Target.X, e.Target.Y, rgb(colorShot))
+		}
+		fmt.Fprintf(&b, `<title>tick %d</title>`, r.tick(e.Tick))
+		fmt.Fprintf(&b, `<animate attributeName="opacity" values="0.2;1;0.2" keyTimes="0;%.4f;1" dur="%.2fs" repeatCount="indefinite"/>`,
+			begin/dur, dur)
+		if e.Explosion {
+			b.WriteString(`</circle>`)
+		} else {
+			b.WriteString(`</line>`)
+		}
+	}
+	for _, t := range tracks {
+		var xs, ys, vis []string
+		for i := 0; i < len(r.Ticks); i++ {
+			p, visible := t.at(i)
+			if !visible {
+				p = t.Positions[0]
+			}
+			xs = append(xs, fmt.Sprint(p.X))
+			ys = append(ys, fmt.Sprint(p.Y))
+			if visible {
+				vis = append(vis, "1")
+			} else {
+				vis = append(vis, "0")
+			}
+		}
+		fmt.Fprintf(&b, `<circle r="2" fill="%s">`, rgb(shipColor(t.Ship.Role)))
+		fmt.Fprintf(&b, `<title>%s %d</title>`, t.Ship.Role, t.Ship.ID)
+		fmt.Fprintf(&b, `<animate attributeName="cx" values="%s" dur="%.2fs" calcMode="discrete" repeatCount="indefinite"/>`,
+			strings.Join(xs, ";"), dur)
+		fmt.Fprintf(&b, `<animate attributeName="cy" values="%s" dur="%.2fs" calcMode="discrete" repeatCount="indefinite"/>`,
+			strings.Join(ys, ";"), dur)
+		fmt.Fprintf(&b, `<animate attributeName="opacity" values="%s" dur="%.2fs" calcMode="discrete" repeatCount="indefinite"/>`,
+			strings.Join(vis, ";"), dur)
+		b.WriteString(`</circle>`)
+	}
+	b.WriteString(`</svg>`)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tick returns the game tick of the i-th recorded state.
+func (r *Replay) tick(i int) int64 {
+	if i < len(r.Ticks) {
+		return r.Ticks[i].Tick
+	}
+	return int64(i)
+}
+
+// at returns the position of the ship at the i-th recorded state.
+func (t *Track) at(i int) (sim.Vec, bool) {
+	j := i - t.First
+	if j < 0 || j >= len(t.Positions) {
+		return sim.Vec{}, false
+	}
+	return t.Positions[j], true
+}
+
+// frame draws game cells scaled onto a paletted image.
+type frame struct {
+	img   *image.Paletted
+	scale int
+	off   int
+}
+
+func (f *frame) set(p sim.Vec, c color.Color) {
+	x0, y0 := f.off+int(p.X)*f.scale, f.off+int(p.Y)*f.scale
+	for dx := 0; dx < f.scale; dx++ {
+		for dy := 0; dy < f.scale; dy++ {
+			f.img.Set(x0+dx, y0+dy, c)
+		}
+	}
+}
+
+func (f *frame) rect(min, max sim.Vec, c color.Color, fill bool) {
+	for x := min.X; x <= max.X; x++ {
+		for y := min.Y; y <= max.Y; y++ {
+			if fill || x == min.X || x == max.X || y == min.Y || y == max.Y {
+				f.set(sim.Vec{X: x, Y: y}, c)
+			}
+		}
+	}
+}
+
+// line draws a line with the Bresenham algorithm up to the frame border.
+func (f *frame) line(a, b sim.Vec, c color.Color) {
+	border := int64(f.off / f.scale)
+	dx, dy := abs(b.X-a.X), -abs(b.Y-a.Y)
+	sx, sy := sign(b.X-a.X), sign(b.Y-a.Y)
+	e := dx + dy
+	for {
+		f.set(a, c)
+		if a == b || abs(a.X) > border || abs(a.Y) > border {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			a.X += sx
+		}
+		if e2 := 2 * e; e2 <= dx {
+			e += dx
+			a.Y += sy
+		}
+	}
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func sign(x int64) int64 {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
+
+// RenderGIF writes the game as an animated GIF with one frame per tick.
+// Each game cell is scale pixels and each frame lasts delay hundredths of a
+// second.
+func RenderGIF(w io.Writer, r *Replay, scale, delay int) error {
+	world := r.World()
+	tracks, events := r.Analyze()
+	ar := world.ArenaRadius + 2
+	size := int(2*ar+1) * scale
+	pr := world.PlanetRadius
+
+	// Trails and events are drawn incrementally on the background.
+	bg := &frame{img: image.NewPaletted(image.Rect(0, 0, size, size), palette), scale: scale, off: int(ar) * scale}
+	bg.rect(sim.Vec{X: -world.ArenaRadius, Y: -world.ArenaRadius}, sim.Vec{X: world.ArenaRadius, Y: world.ArenaRadius}, colorArena, false)
+	bg.rect(sim.Vec{X: -pr, Y: -pr}, sim.Vec{X: pr, Y: pr}, colorPlanet, true)
+
+	anim := &gif.GIF{}
+	for i := range r.Ticks {
+		for _, t := range tracks {
+			if p, ok := t.at(i); ok {
+				bg.set(p, trailColor(t.Ship.Role))
+			}
+		}
+		f := &frame{img: image.NewPaletted(bg.img.Rect, palette), scale: scale, off: bg.off}
+		copy(f.img.Pix, bg.img.Pix)
+		for _, e := range events {
+			if e.Tick != i {
+				continue
+			}
+			if e.Explosion {
+				rad := sim.Vec{X: explosionRadius, Y: explosionRadius}
+				f.rect(e.Pos.Sub(rad), e.Pos.Add(rad), colorExplosion, true)
+			} else {
+				f.line(e.Pos, e.Target, colorShot)
+			}
+		}
+		for _, t := range tracks {
+			if p, ok := t.at(i); ok {
+				f.rect(p.Sub(sim.Vec{X: 1, Y: 1}), p.Add(sim.Vec{X: 1, Y: 1}), shipColor(t.Ship.Role), true)
+			}
+		}
+		anim.Image = append(anim.Image, f.img)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	if len(anim.Image) == 0 {
+		return fmt.Errorf("Replay has no ticks")
+	}
+	return gif.EncodeAll(w, anim)
+}
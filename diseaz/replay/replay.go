@@ -0,0 +1,161 @@
+// Package replay records games and renders them as pictures.
+package replay
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+// Outcome is the result of a finished game.
+type Outcome struct {
+	Winner proto.Role
+	Ticks  int64
+}
+
+// Replay is a game as seen by one player.
+type Replay struct {
+	StaticInfo *proto.GameStaticInfo
+	Ticks      []*proto.GameState
+	Outcome    *Outcome `json:",omitempty"`
+}
+
+// Winner returns the winner of a game that finished in state: the attacker
+// wins by destroying every defender ship.
+func Winner(state *proto.GameState) proto.Role {
+	if state != nil {
+		for _, s := range state.Ships {
+			if s.Ship.Role == proto.RoleDefender {
+				return proto.RoleDefender
+			}
+		}
+	}
+	return proto.RoleAttacker
+}
+
+// Add records a game response. Responses for an already recorded tick are
+// ignored.
+func (r *Replay) Add(gr *proto.GameResponse) {
+	if gr == nil {
+		return
+	}
+	if gr.StaticInfo != nil {
+		r.StaticInfo = gr.StaticInfo
+	}
+	if gr.State != nil {
+		if n := len(r.Ticks); n == 0 || r.Ticks[n-1].Tick != gr.State.Tick {
+			r.Ticks = append(r.Ticks, gr.State)
+		}
+	}
+	if gr.Stage == proto.GameFinished && r.Outcome == nil {
+		r.Outcome = &Outcome{Winner: Winner(gr.State)}
+		if gr.State != nil {
+			r.Outcome.Ticks = gr.State.Tick
+		}
+	}
+}
+
+// World returns the planet and arena of the game.
+func (r *Replay) World() sim.World {
+	if r.StaticInfo != nil {
+		return r.StaticInfo.World
+	}
+	if len(r.Ticks) > 0 {
+		return r.Ticks[0].World
+	}
+	return sim.DefaultWorld
+}
+
+func (r *Replay) Save(fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func Load(fn string) (*Replay, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := &Replay{}
+	if err := json.NewDecoder(f).Decode(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ShipKey identifies a ship during a game.
+type ShipKey struct {
+	Role proto.Role
+	ID   int64
+}
+
+// Event is something that happened to a ship in a tick.
+type Event struct {
+	Tick int
+	Ship ShipKey
+	// Pos is the position of the ship, or of the explosion.
+	Pos sim.Vec
+	// Target and Power describe a shot.
+	Target sim.Vec
+	Power  int64
+	// Explosion is set for detonations and destroyed ships.
+	Explosion bool
+}
+
+// Track is the trajectory of one ship.
+type Track struct {
+	Ship ShipKey
+	// First is the index of the tick of the first position.
+	First     int
+	Positions []sim.Vec
+}
+
+// Analyze extracts the ship trajectories, shots and explosions.
+func (r *Replay) Analyze() (tracks []*Track, events []Event) {
+	byShip := make(map[ShipKey]*Track)
+	for i, st := range r.Ticks {
+		seen := make(map[ShipKey]bool)
+		for _, sc := range st.Ships {
+			k := ShipKey{Role: sc.Ship.Role, ID: sc.Ship.ID}
+			seen[k] = true
+			t := byShip[k]
+			if t == nil {
+				t = &Track{Ship: k, First: i}
+				byShip[k] = t
+				tracks = append(tracks, t)
+			}
+			t.Positions = append(t.Positions, sc.Ship.Position)
+			for _, c := range sc.Commands {
+				switch c.Type {
+				case proto.CommandShoot:
+					e := Event{Tick: i, Ship: k, Pos: sc.Ship.Position, Target: c.Vec}
+					if len(c.Values) > 0 {
+						e.Power = c.Values[0]
+					}
+					events = append(events, e)
+				case proto.CommandDetonate:
+					events = append(events, Event{Tick: i, Ship: k, Pos: sc.Ship.Position, Explosion: true})
+				}
+			}
+		}
+		for _, t := range tracks {
+			last := t.First + len(t.Positions) - 1
+			if !seen[t.Ship] && last == i-1 {
+				events = append(events, Event{Tick: i, Ship: t.Ship, Pos: t.Positions[len(t.Positions)-1], Explosion: true})
+			}
+		}
+	}
+	return tracks, events
+}
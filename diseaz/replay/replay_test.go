@@ -0,0 +1,112 @@
+package replay
+
+import (
+	"bytes"
+	"image/gif"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func ship(role proto.Role, id int64, x, y int64, cmds ...proto.AppliedCommand) *proto.ShipAndCommands {
+	return &proto.ShipAndCommands{
+		Ship: proto.ShipState{
+			Role: role,
+			ID:   id,
+			Ship: sim.Ship{Position: sim.Vec{X: x, Y: y}, MaxHeat: 64},
+		},
+		Commands: cmds,
+	}
+}
+
+func testReplay() *Replay {
+	info := &proto.GameStaticInfo{MaxTicks: 256, Role: proto.RoleAttacker, World: sim.DefaultWorld}
+	r := &Replay{}
+	r.Add(&proto.GameResponse{Stage: proto.GamePending, StaticInfo: info})
+	r.Add(&proto.GameResponse{Stage: proto.GameStarted, StaticInfo: info, State: &proto.GameState{
+		Tick:  0,
+		World: sim.DefaultWorld,
+		Ships: []*proto.ShipAndCommands{
+			ship(proto.RoleAttacker, 0, 0, 48),
+			ship(proto.RoleDefender, 1, 0, -48),
+		},
+	}})
+	shot := proto.AppliedCommand{Type: proto.CommandShoot, Vec: sim.Vec{X: -7, Y: -46}, Values: []int64{32, 10, 4}}
+	r.Add(&proto.GameResponse{Stage: proto.GameStarted, StaticInfo: info, State: &proto.GameState{
+		Tick:  1,
+		World: sim.DefaultWorld,
+		Ships: []*proto.ShipAndCommands{
+			ship(proto.RoleAttacker, 0, 7, 47, shot),
+			ship(proto.RoleDefender, 1, -7, -46),
+		},
+	}})
+	r.Add(&proto.GameResponse{Stage: proto.GameFinished, StaticInfo: info, State: &proto.GameState{
+		Tick:  2,
+		World: sim.DefaultWorld,
+		Ships: []*proto.ShipAndCommands{
+			ship(proto.RoleAttacker, 0, 14, 45),
+		},
+	}})
+	return r
+}
+
+func TestAdd(t *testing.T) {
+	r := testReplay()
+	require.Len(t, r.Ticks, 3)
+	require.NotNil(t, r.Outcome)
+	assert.Equal(t, Outcome{Winner: proto.RoleAttacker, Ticks: 2}, *r.Outcome)
+
+	// The finished game is answered with the same state again.
+	r.Add(&proto.GameResponse{Stage: proto.GameFinished, State: r.Ticks[2]})
+	assert.Len(t, r.Ticks, 3)
+}
+
+func TestAnalyze(t *testing.T) {
+	tracks, events := testReplay().Analyze()
+	require.Len(t, tracks, 2)
+	assert.Equal(t, []sim.Vec{{X: 0, Y: 48}, {X: 7, Y: 47}, {X: 14, Y: 45}}, tracks[0].Positions)
+	assert.Equal(t, 2, len(tracks[1].Positions))
+
+	assert.Equal(t, []Event{
+		{Tick: 1, Ship: ShipKey{Role: proto.RoleAttacker, ID: 0}, Pos: sim.Vec{X: 7, Y: 47}, Target: sim.Vec{X: -7, Y: -46}, Power: 32},
+		{Tick: 2, Ship: ShipKey{Role: proto.RoleDefender, ID: 1}, Pos: sim.Vec{X: -7, Y: -46}, Explosion: true},
+	}, events)
+}
+
+func TestSaveLoad(t *testing.T) {
+	r := testReplay()
+	dir, err := ioutil.TempDir("", "replay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "game.json")
+	require.NoError(t, r.Save(fn))
+	loaded, err := Load(fn)
+	require.NoError(t, err)
+	assert.Equal(t, r, loaded)
+}
+
+func TestRender(t *testing.T) {
+	r := testReplay()
+
+	var svg bytes.Buffer
+	require.NoError(t, RenderSVG(&svg, r, 0.1))
+	assert.True(t, strings.HasPrefix(svg.String(), "<svg"))
+	assert.Equal(t, 2, strings.Count(svg.String(), "<polyline"))
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderGIF(&buf, r, 2, 5))
+	anim, err := gif.DecodeAll(&buf)
+	require.NoError(t, err)
+	assert.Len(t, anim.Image, 3)
+
+	assert.Error(t, RenderGIF(&buf, &Replay{}, 2, 5))
+}
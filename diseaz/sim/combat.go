@@ -0,0 +1,53 @@
+package sim
+
+const (
+	// DetonationPower is the damage of a detonation next to the ship.
+	DetonationPower = 128
+	// DetonationFalloff is the damage lost per cell of distance.
+	DetonationFalloff = 16
+)
+
+// Health is the damage the ship takes before it is destroyed.
+func (s *Ship) Health() int64 {
+	return s.MaxHeat - s.Heat + s.Params.Fuel + s.Params.Power + s.Params.Cooling
+}
+
+// TakeDamage heats the ship up by d.
+func (s *Ship) TakeDamage(d int64) {
+	if s.Destroyed || d <= 0 {
+		return
+	}
+	s.Heat += d
+	s.overheat()
+}
+
+// Alignment is the laser efficiency in percent for a shot along d. Lasers are
+// strongest along the axes and the diagonals and useless halfway between.
+func Alignment(d Vec) int64 {
+	mx := max(abs(d.X), abs(d.Y))
+	if mx == 0 {
+		return 100
+	}
+	mn := min(abs(d.X), abs(d.Y))
+	return abs(mx-2*mn) * 100 / mx
+}
+
+// ShotDamage is the damage of a laser shot with power from from at target.
+func ShotDamage(from, target Vec, power int64) int64 {
+	d := target.Sub(from)
+	return max(3*power*Alignment(d)/100-d.Norm(), 0)
+}
+
+// MissDamage reduces the damage of a shot that missed its target by miss
+// cells: it halves with every cell.
+func MissDamage(damage, miss int64) int64 {
+	if miss >= 63 {
+		return 0
+	}
+	return damage >> uint(miss)
+}
+
+// DetonationDamage is the damage of a detonation dist cells away.
+func DetonationDamage(dist int64) int64 {
+	return max(DetonationPower-DetonationFalloff*dist, 0)
+}
@@ -0,0 +1,207 @@
+// Package sim simulates the discrete physics of the alien battle game.
+package sim
+
+const (
+	// MaxThrust is the largest component of an accelerate vector.
+	MaxThrust = 2
+	// ThrustHeat is the heat produced by one unit of thrust.
+	ThrustHeat = 8
+)
+
+type Vec struct {
+	X int64 `json:""`
+	Y int64 `json:""`
+}
+
+func (v Vec) Add(o Vec) Vec {
+	return Vec{X: v.X + o.X, Y: v.Y + o.Y}
+}
+
+func (v Vec) Sub(o Vec) Vec {
+	return Vec{X: v.X - o.X, Y: v.Y - o.Y}
+}
+
+// Norm is the Chebyshev length of v.
+func (v Vec) Norm() int64 {
+	return max(abs(v.X), abs(v.Y))
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func sign(x int64) int64 {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
+
+func max(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Gravity pulls towards the square planet along the dominant axis, or along
+// both axes on the diagonals.
+func Gravity(p Vec) Vec {
+	var g Vec
+	if abs(p.X) >= abs(p.Y) {
+		g.X = -sign(p.X)
+	}
+	if abs(p.Y) >= abs(p.X) {
+		g.Y = -sign(p.Y)
+	}
+	return g
+}
+
+type Params struct {
+	Fuel    int64 `json:""`
+	Power   int64 `json:""`
+	Cooling int64 `json:""`
+	Clones  int64 `json:""`
+}
+
+// Cost is the number of points spent on p at START.
+func (p Params) Cost() int64 {
+	return p.Fuel + 4*p.Power + 12*p.Cooling + 2*p.Clones
+}
+
+type Ship struct {
+	Position  Vec    `json:""`
+	Velocity  Vec    `json:""`
+	Params    Params `json:""`
+	Heat      int64  `json:""`
+	MaxHeat   int64  `json:""`
+	Destroyed bool   `json:""`
+}
+
+// CanAccelerate reports whether the ship is able to apply thrust.
+func (s *Ship) CanAccelerate(thrust Vec) bool {
+	return !s.Destroyed && thrust.Norm() <= MaxThrust && thrust.Norm() <= s.Params.Fuel
+}
+
+// CanShoot reports whether the ship is able to fire a laser with power.
+func (s *Ship) CanShoot(power int64) bool {
+	return !s.Destroyed && power >= 0 && power <= s.Params.Power
+}
+
+// Shoot heats the ship up by the laser power.
+func (s *Ship) Shoot(power int64) {
+	s.Heat += power
+}
+
+// cool dissipates heat.
+func (s *Ship) cool() {
+	s.Heat = max(s.Heat-s.Params.Cooling, 0)
+	s.overheat()
+}
+
+// overheat burns heat above MaxHeat from fuel first, then power and then
+// cooling. A ship with nothing left to burn is destroyed.
+func (s *Ship) overheat() {
+	over := s.Heat - s.MaxHeat
+	if over <= 0 {
+		return
+	}
+	s.Heat = s.MaxHeat
+	for _, p := range []*int64{&s.Params.Fuel, &s.Params.Power, &s.Params.Cooling} {
+		burnt := min(*p, over)
+		*p -= burnt
+		over -= burnt
+	}
+	if s.Params.Fuel == 0 && s.Params.Power == 0 && s.Params.Cooling == 0 {
+		s.Destroyed = true
+	}
+}
+
+type World struct {
+	PlanetRadius int64 `json:""`
+	ArenaRadius  int64 `json:""`
+}
+
+// DefaultWorld is the planet and arena of the tournament games.
+var DefaultWorld = World{PlanetRadius: 16, ArenaRadius: 128}
+
+// InPlanet reports whether p is inside the planet.
+func (w World) InPlanet(p Vec) bool {
+	return abs(p.X) <= w.PlanetRadius && abs(p.Y) <= w.PlanetRadius
+}
+
+// InArena reports whether p is inside the arena.
+func (w World) InArena(p Vec) bool {
+	return abs(p.X) <= w.ArenaRadius && abs(p.Y) <= w.ArenaRadius
+}
+
+// Step advances the ship by one tick. Thrust that the ship cannot afford is
+// ignored. A ship that hits the planet or leaves the arena is destroyed.
+func (w World) Step(s Ship, thrust Vec) Ship {
+	if s.Destroyed {
+		return s
+	}
+	if !s.CanAccelerate(thrust) {
+		thrust = Vec{}
+	}
+	n := thrust.Norm()
+	s.Params.Fuel -= n
+	s.Heat += n * ThrustHeat
+
+	s.Velocity = s.Velocity.Add(Gravity(s.Position)).Sub(thrust)
+	s.Position = s.Position.Add(s.Velocity)
+	s.cool()
+
+	if w.InPlanet(s.Position) || !w.InArena(s.Position) {
+		s.Destroyed = true
+	}
+	return s
+}
+
+// Trajectory returns the ship states after each of the next n ticks without
+// thrust. It stops early if the ship gets destroyed.
+func (w World) Trajectory(s Ship, n int) []Ship {
+	r := make([]Ship, 0, n)
+	for i := 0; i < n && !s.Destroyed; i++ {
+		s = w.Step(s, Vec{})
+		r = append(r, s)
+	}
+	return r
+}
+
+// Predict returns the ship state after n ticks without thrust.
+func (w World) Predict(s Ship, n int) Ship {
+	for i := 0; i < n && !s.Destroyed; i++ {
+		s = w.Step(s, Vec{})
+	}
+	return s
+}
+
+// Lifetime returns how many of the next n ticks the ship survives without
+// thrust.
+func (w World) Lifetime(s Ship, n int) int {
+	for i := 0; i < n; i++ {
+		if s = w.Step(s, Vec{}); s.Destroyed {
+			return i
+		}
+	}
+	return n
+}
+
+// Survives reports whether the ship survives n ticks without thrust.
+func (w World) Survives(s Ship, n int) bool {
+	return !w.Predict(s, n).Destroyed
+}
@@ -0,0 +1,92 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGravity(t *testing.T) {
+	assert.Equal(t, Vec{X: -1}, Gravity(Vec{X: 30, Y: 5}))
+	assert.Equal(t, Vec{Y: 1}, Gravity(Vec{X: 5, Y: -30}))
+	assert.Equal(t, Vec{X: 1, Y: -1}, Gravity(Vec{X: -20, Y: 20}))
+	assert.Equal(t, Vec{}, Gravity(Vec{}))
+}
+
+func TestStep(t *testing.T) {
+	w := DefaultWorld
+	s := Ship{
+		Position: Vec{X: 16, Y: -48},
+		Params:   Params{Fuel: 10, Power: 5, Cooling: 2, Clones: 1},
+		MaxHeat:  64,
+	}
+
+	s1 := w.Step(s, Vec{X: 1, Y: 0})
+	assert.Equal(t, Vec{X: -1, Y: 1}, s1.Velocity)
+	assert.Equal(t, Vec{X: 15, Y: -47}, s1.Position)
+	assert.Equal(t, int64(9), s1.Params.Fuel)
+	assert.Equal(t, int64(ThrustHeat-2), s1.Heat)
+	assert.False(t, s1.Destroyed)
+
+	// Thrust beyond MaxThrust is ignored.
+	s2 := w.Step(s, Vec{X: 3})
+	assert.Equal(t, Vec{X: 0, Y: 1}, s2.Velocity)
+	assert.Equal(t, int64(10), s2.Params.Fuel)
+}
+
+func TestStepDestroyed(t *testing.T) {
+	w := DefaultWorld
+
+	falling := Ship{Position: Vec{X: 0, Y: 20}, Velocity: Vec{Y: -3}}
+	assert.True(t, w.Step(falling, Vec{}).Destroyed)
+
+	escaping := Ship{Position: Vec{X: 120, Y: 0}, Velocity: Vec{X: 10}}
+	assert.True(t, w.Step(escaping, Vec{}).Destroyed)
+
+	assert.Len(t, w.Trajectory(Ship{Position: Vec{X: 0, Y: 48}}, 100), 8)
+}
+
+func TestOverheat(t *testing.T) {
+	s := Ship{Params: Params{Fuel: 3, Power: 10, Cooling: 1}, MaxHeat: 64, Heat: 60}
+	s.Shoot(10)
+	s.cool()
+	assert.Equal(t, int64(64), s.Heat)
+	assert.Equal(t, Params{Fuel: 0, Power: 8, Cooling: 1}, s.Params)
+}
+
+func TestPredictOrbit(t *testing.T) {
+	w := DefaultWorld
+	s := Ship{Position: Vec{X: 0, Y: 48}, Velocity: Vec{X: 7}}
+	assert.True(t, w.Survives(s, 256))
+
+	p := w.Predict(s, 3)
+	tr := w.Trajectory(s, 3)
+	assert.Equal(t, p, tr[2])
+}
+
+func TestShotDamage(t *testing.T) {
+	assert.Equal(t, int64(100), Alignment(Vec{X: 10}))
+	assert.Equal(t, int64(100), Alignment(Vec{X: -7, Y: 7}))
+	assert.Equal(t, int64(0), Alignment(Vec{X: 10, Y: 5}))
+
+	assert.Equal(t, int64(3*20-10), ShotDamage(Vec{}, Vec{Y: 10}, 20))
+	assert.Equal(t, int64(0), ShotDamage(Vec{}, Vec{X: 10, Y: 5}, 20))
+	assert.Equal(t, int64(12), MissDamage(50, 2))
+	assert.Equal(t, int64(0), MissDamage(50, 100))
+}
+
+func TestTakeDamage(t *testing.T) {
+	s := Ship{Params: Params{Fuel: 10, Power: 5, Cooling: 1}, MaxHeat: 64, Heat: 4}
+	assert.Equal(t, int64(60+16), s.Health())
+
+	s.TakeDamage(70)
+	assert.Equal(t, int64(64), s.Heat)
+	assert.Equal(t, Params{Fuel: 0, Power: 5, Cooling: 1}, s.Params)
+	assert.False(t, s.Destroyed)
+
+	s.TakeDamage(s.Health())
+	assert.True(t, s.Destroyed)
+
+	assert.Equal(t, int64(DetonationPower), DetonationDamage(0))
+	assert.Equal(t, int64(0), DetonationDamage(100))
+}
@@ -0,0 +1,174 @@
+package strategy
+
+import (
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func init() {
+	Register("hunter", func() Strategy { return &Hunter{} })
+}
+
+// Attack is a planned laser shot or detonation.
+type Attack struct {
+	Detonate bool
+	Target   sim.Vec
+	Power    int64
+	// Damage is the expected damage to the enemies.
+	Damage int64
+	// Kills is the number of enemies expected to be destroyed.
+	Kills int
+}
+
+// better orders attacks by kills, then damage, then lower power.
+func (a Attack) better(o Attack) bool {
+	if a.Kills != o.Kills {
+		return a.Kills > o.Kills
+	}
+	if a.Damage != o.Damage {
+		return a.Damage > o.Damage
+	}
+	return a.Power < o.Power
+}
+
+// Gunner plans laser shots and detonations against enemy ships. Enemies are
+// expected to coast, shots hit after the ships have moved.
+type Gunner struct {
+	World sim.World
+}
+
+func NewGunner(w sim.World) *Gunner {
+	return &Gunner{World: w}
+}
+
+// Forecast returns the positions of the ship over the next n ticks without
+// thrust.
+func (g *Gunner) Forecast(s sim.Ship, n int) []sim.Vec {
+	var ps []sim.Vec
+	for _, t := range g.World.Trajectory(s, n) {
+		ps = append(ps, t.Position)
+	}
+	return ps
+}
+
+// PowerBudget is the strongest shot that keeps the ship within its heat limit
+// after thrust and cooling.
+func PowerBudget(s sim.Ship, thrust sim.Vec) int64 {
+	budget := s.MaxHeat + s.Params.Cooling - s.Heat - thrust.Norm()*sim.ThrustHeat
+	if budget > s.Params.Power {
+		budget = s.Params.Power
+	}
+	if budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// Shot returns the best laser shot at the enemies, using the least power
+// that still destroys the target.
+func (g *Gunner) Shot(me sim.Ship, thrust sim.Vec, enemies []sim.Ship) (Attack, bool) {
+	from := g.World.Step(me, thrust).Position
+	budget := PowerBudget(me, thrust)
+
+	var best Attack
+	found := false
+	for _, e := range enemies {
+		next := g.World.Step(e, sim.Vec{})
+		if e.Destroyed || next.Destroyed {
+			continue
+		}
+		a := Attack{Target: next.Position, Power: budget, Damage: sim.ShotDamage(from, next.Position, budget)}
+		if a.Damage <= 0 {
+			continue
+		}
+		if health := next.Health(); a.Damage >= health {
+			a.Kills = 1
+			for p := int64(1); p < budget; p++ {
+				if d := sim.ShotDamage(from, next.Position, p); d >= health {
+					a.Power, a.Damage = p, d
+					break
+				}
+			}
+		}
+		if !found || a.better(best) {
+			best, found = a, true
+		}
+	}
+	return best, found
+}
+
+// Detonation returns the expected effect of detonating me now.
+func (g *Gunner) Detonation(me sim.Ship, enemies []sim.Ship) Attack {
+	a := Attack{Detonate: true, Target: me.Position}
+	for _, e := range enemies {
+		next := g.World.Step(e, sim.Vec{})
+		if e.Destroyed || next.Destroyed {
+			continue
+		}
+		d := sim.DetonationDamage(next.Position.Sub(me.Position).Norm())
+		a.Damage += d
+		if d >= next.Health() {
+			a.Kills++
+		}
+	}
+	return a
+}
+
+// Plan chooses between shooting and detonating for me moving with thrust.
+// Detonating wins when it destroys more enemies than the shot, or when me is
+// about to be destroyed anyway.
+func (g *Gunner) Plan(me sim.Ship, thrust sim.Vec, enemies []sim.Ship) (Attack, bool) {
+	shot, ok := g.Shot(me, thrust, enemies)
+	boom := g.Detonation(me, enemies)
+	doomed := g.World.Step(me, thrust).Destroyed
+	if boom.Kills > shot.Kills || (doomed && boom.Damage > 0) {
+		return boom, true
+	}
+	return shot, ok
+}
+
+// Hunter keeps its ships on orbit and attacks the enemy ships with lasers,
+// detonating when it pays off.
+type Hunter struct {
+	pilot  *Autopilot
+	gunner *Gunner
+}
+
+func (h *Hunter) Start(info *proto.GameStaticInfo) sim.Params {
+	p := sim.Params{Power: 48, Cooling: 8, Clones: 1}
+	p.Fuel = info.Constraints.MaxPoints - p.Cost()
+	if p.Fuel <= 0 {
+		return sim.Params{Fuel: 1, Power: 1, Cooling: 1, Clones: 1}
+	}
+	return p
+}
+
+func (h *Hunter) Commands(info *proto.GameStaticInfo, state *proto.GameState) []proto.Command {
+	if h.pilot == nil {
+		h.pilot = NewAutopilot(info.World)
+		h.gunner = NewGunner(info.World)
+	}
+	var enemies []sim.Ship
+	for _, s := range ShipsOf(info.Role.Opponent(), state) {
+		enemies = append(enemies, s.Ship.Ship)
+	}
+
+	var cmds []proto.Command
+	horizon := remainingTicks(info, state)
+	for _, s := range MyShips(info, state) {
+		id := s.Ship.ID
+		thrust := h.pilot.ShipThrust(s.Ship, horizon)
+		a, ok := h.gunner.Plan(s.Ship.Ship, thrust, enemies)
+		if ok && a.Detonate {
+			cmds = append(cmds, proto.Detonate{ShipID: id})
+			continue
+		}
+		if thrust != (sim.Vec{}) {
+			cmds = append(cmds, proto.Accelerate{ShipID: id, Vec: thrust})
+		}
+		if ok && a.Power > 0 {
+			cmds = append(cmds, proto.Shoot{ShipID: id, Target: a.Target, Power: a.Power})
+		}
+	}
+	return cmds
+}
@@ -0,0 +1,64 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func TestPowerBudget(t *testing.T) {
+	s := sim.Ship{Params: sim.Params{Power: 48, Cooling: 8}, MaxHeat: 64, Heat: 30}
+	assert.Equal(t, int64(42), PowerBudget(s, sim.Vec{}))
+	assert.Equal(t, int64(26), PowerBudget(s, sim.Vec{X: 1, Y: -2}))
+	s.Heat = 0
+	assert.Equal(t, int64(48), PowerBudget(s, sim.Vec{}))
+}
+
+func TestGunnerShot(t *testing.T) {
+	g := NewGunner(sim.DefaultWorld)
+	me := sim.Ship{
+		Position: sim.Vec{X: -40, Y: 48},
+		Velocity: sim.Vec{X: 1},
+		Params:   sim.Params{Fuel: 100, Power: 48, Cooling: 8},
+		MaxHeat:  64,
+	}
+	aligned := sim.Ship{Position: sim.Vec{X: 40, Y: 48}, Velocity: sim.Vec{X: -1}, Params: sim.Params{Fuel: 100}, MaxHeat: 64}
+	skewed := sim.Ship{Position: sim.Vec{X: 40, Y: 88}, Params: sim.Params{Fuel: 100}, MaxHeat: 64}
+
+	a, ok := g.Plan(me, sim.Vec{}, []sim.Ship{skewed, aligned})
+	require.True(t, ok)
+	assert.False(t, a.Detonate)
+	assert.Equal(t, g.World.Step(aligned, sim.Vec{}).Position, a.Target)
+	assert.Equal(t, int64(48), a.Power)
+	assert.Greater(t, a.Damage, int64(0))
+	assert.Equal(t, 0, a.Kills)
+
+	// A weak enemy needs only part of the power.
+	weak := aligned
+	weak.Heat, weak.Params = 60, sim.Params{}
+	a, ok = g.Plan(me, sim.Vec{}, []sim.Ship{weak})
+	require.True(t, ok)
+	assert.Equal(t, 1, a.Kills)
+	assert.Less(t, a.Power, int64(48))
+	next := g.World.Step(weak, sim.Vec{})
+	assert.GreaterOrEqual(t, a.Damage, next.Health())
+}
+
+func TestGunnerDetonate(t *testing.T) {
+	g := NewGunner(sim.DefaultWorld)
+	me := sim.Ship{Position: sim.Vec{X: 30, Y: 48}, Params: sim.Params{Fuel: 10, Power: 4}, MaxHeat: 64}
+	enemy := sim.Ship{Position: sim.Vec{X: 33, Y: 50}, Params: sim.Params{Fuel: 2, Power: 2}, MaxHeat: 64}
+
+	a, ok := g.Plan(me, sim.Vec{}, []sim.Ship{enemy})
+	require.True(t, ok)
+	assert.True(t, a.Detonate)
+	assert.Equal(t, 1, a.Kills)
+
+	far := enemy
+	far.Position = sim.Vec{X: 33, Y: -60}
+	a, _ = g.Plan(me, sim.Vec{}, []sim.Ship{far})
+	assert.False(t, a.Detonate)
+}
@@ -0,0 +1,153 @@
+package strategy
+
+import (
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func init() {
+	Register("orbit", func() Strategy { return &Orbit{} })
+}
+
+// Autopilot keeps a ship on an orbit that neither hits the planet nor leaves
+// the arena, spending as little fuel as possible.
+type Autopilot struct {
+	World sim.World
+	// MaxBurns limits the length of the thrust sequences searched.
+	MaxBurns int
+	// MaxThrust limits the thrust of one tick.
+	MaxThrust int64
+	// FuelReserve is the fuel the autopilot never spends.
+	FuelReserve int64
+}
+
+func NewAutopilot(w sim.World) *Autopilot {
+	return &Autopilot{World: w, MaxBurns: 2, MaxThrust: sim.MaxThrust}
+}
+
+// plan is a thrust sequence applied on consecutive ticks followed by
+// coasting.
+type plan struct {
+	burns    []sim.Vec
+	lifetime int
+	fuel     int64
+}
+
+// better orders plans by lifetime, then fuel, then the number of burns.
+func (p plan) better(o plan) bool {
+	if p.lifetime != o.lifetime {
+		return p.lifetime > o.lifetime
+	}
+	if p.fuel != o.fuel {
+		return p.fuel < o.fuel
+	}
+	return len(p.burns) < len(o.burns)
+}
+
+// thrusts returns the thrust vectors allowed with fuel, cheapest first.
+func (a *Autopilot) thrusts(fuel int64) []sim.Vec {
+	limit := a.MaxThrust
+	if fuel < limit {
+		limit = fuel
+	}
+	var vs []sim.Vec
+	for n := int64(1); n <= limit; n++ {
+		for x := -n; x <= n; x++ {
+			for y := -n; y <= n; y++ {
+				if v := (sim.Vec{X: x, Y: y}); v.Norm() == n {
+					vs = append(vs, v)
+				}
+			}
+		}
+	}
+	return vs
+}
+
+// Plan returns the cheapest thrust sequence that keeps the ship alive for
+// horizon ticks. If there is none, it returns the sequence that survives
+// longest and false.
+func (a *Autopilot) Plan(s sim.Ship, horizon int) ([]sim.Vec, bool) {
+	best := plan{lifetime: a.World.Lifetime(s, horizon)}
+	if best.lifetime < horizon {
+		a.search(s, horizon, nil, 0, &best)
+	}
+	return best.burns, best.lifetime >= horizon
+}
+
+func (a *Autopilot) search(s sim.Ship, horizon int, burns []sim.Vec, fuel int64, best *plan) {
+	if len(burns) >= a.MaxBurns || horizon <= 0 {
+		return
+	}
+	for _, v := range a.thrusts(s.Params.Fuel - a.FuelReserve) {
+		next := a.World.Step(s, v)
+		if next.Destroyed {
+			continue
+		}
+		p := plan{
+			burns:    append(append([]sim.Vec(nil), burns...), v),
+			lifetime: len(burns) + 1 + a.World.Lifetime(next, horizon-1),
+			fuel:     fuel + v.Norm(),
+		}
+		if p.better(*best) {
+			*best = p
+		}
+		if p.lifetime < len(burns)+horizon {
+			a.search(next, horizon-1, p.burns, p.fuel, best)
+		}
+	}
+}
+
+// Thrust returns the thrust for the current tick.
+func (a *Autopilot) Thrust(s sim.Ship, horizon int) sim.Vec {
+	burns, _ := a.Plan(s, horizon)
+	if len(burns) == 0 {
+		return sim.Vec{}
+	}
+	return burns[0]
+}
+
+// ShipThrust is Thrust limited by the thrust the ship reports.
+func (a *Autopilot) ShipThrust(ss proto.ShipState, horizon int) sim.Vec {
+	pilot := *a
+	if ss.MaxThrust > 0 && ss.MaxThrust < pilot.MaxThrust {
+		pilot.MaxThrust = ss.MaxThrust
+	}
+	return pilot.Thrust(ss.Ship, horizon)
+}
+
+// Orbit spends nearly all points on fuel and keeps every ship on a stable
+// orbit until the game ends.
+type Orbit struct {
+	pilot *Autopilot
+}
+
+func (o *Orbit) Start(info *proto.GameStaticInfo) sim.Params {
+	p := sim.Params{Cooling: 2, Clones: 1}
+	p.Fuel = info.Constraints.MaxPoints - p.Cost()
+	if p.Fuel <= 0 {
+		return sim.Params{Fuel: 1, Power: 1, Cooling: 1, Clones: 1}
+	}
+	return p
+}
+
+// remainingTicks returns the number of ticks left in the game.
+func remainingTicks(info *proto.GameStaticInfo, state *proto.GameState) int {
+	if info.MaxTicks <= state.Tick {
+		return 0
+	}
+	return int(info.MaxTicks - state.Tick)
+}
+
+func (o *Orbit) Commands(info *proto.GameStaticInfo, state *proto.GameState) []proto.Command {
+	if o.pilot == nil {
+		o.pilot = NewAutopilot(info.World)
+	}
+	var cmds []proto.Command
+	horizon := remainingTicks(info, state)
+	for _, s := range MyShips(info, state) {
+		if v := o.pilot.ShipThrust(s.Ship, horizon); v != (sim.Vec{}) {
+			cmds = append(cmds, proto.Accelerate{ShipID: s.Ship.ID, Vec: v})
+		}
+	}
+	return cmds
+}
@@ -0,0 +1,53 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func TestAutopilotHoldsOrbit(t *testing.T) {
+	const maxTicks = 256
+	w := sim.DefaultWorld
+	info := &proto.GameStaticInfo{
+		MaxTicks:    maxTicks,
+		Role:        proto.RoleDefender,
+		Constraints: proto.Constraints{MaxPoints: 512, MaxHeat: 64},
+		World:       w,
+	}
+
+	for _, start := range []sim.Vec{{X: 16, Y: -48}, {X: -16, Y: 48}, {X: 100, Y: 3}} {
+		o := &Orbit{}
+		params := o.Start(info)
+		require.LessOrEqual(t, params.Cost(), info.Constraints.MaxPoints)
+
+		s := sim.Ship{Position: start, Params: params, MaxHeat: 64}
+		burns := 0
+		for tick := int64(0); tick < maxTicks; tick++ {
+			state := &proto.GameState{Tick: tick, World: w, Ships: []*proto.ShipAndCommands{
+				{Ship: proto.ShipState{Role: proto.RoleDefender, Ship: s, MaxThrust: sim.MaxThrust}},
+			}}
+			var thrust sim.Vec
+			for _, c := range o.Commands(info, state) {
+				thrust = c.(proto.Accelerate).Vec
+				burns++
+			}
+			s = w.Step(s, thrust)
+			require.False(t, s.Destroyed, "start %v, tick %d", start, tick)
+		}
+		assert.LessOrEqual(t, params.Fuel-s.Params.Fuel, int64(20), "start %v", start)
+		assert.LessOrEqual(t, burns, 10, "start %v", start)
+	}
+}
+
+func TestAutopilotCoasts(t *testing.T) {
+	a := NewAutopilot(sim.DefaultWorld)
+	s := sim.Ship{Position: sim.Vec{X: 0, Y: 48}, Velocity: sim.Vec{X: 7}, Params: sim.Params{Fuel: 10}}
+	burns, ok := a.Plan(s, 256)
+	assert.True(t, ok)
+	assert.Empty(t, burns)
+}
@@ -0,0 +1,21 @@
+package strategy
+
+import (
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func init() {
+	Register("idle", func() Strategy { return Idle{} })
+}
+
+// Idle starts with minimal parameters and sends no commands.
+type Idle struct{}
+
+func (Idle) Start(info *proto.GameStaticInfo) sim.Params {
+	return sim.Params{Fuel: 1, Power: 1, Cooling: 1, Clones: 1}
+}
+
+func (Idle) Commands(info *proto.GameStaticInfo, state *proto.GameState) []proto.Command {
+	return nil
+}
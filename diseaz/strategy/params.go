@@ -0,0 +1,130 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+// ParamsConfig is the START allocation for each role, as written by
+// galaxy-optimize.
+type ParamsConfig struct {
+	// Strategy and MaxPoints describe what the allocations were tuned for.
+	Strategy  string `json:",omitempty"`
+	MaxPoints int64  `json:",omitempty"`
+	Attacker  *sim.Params
+	Defender  *sim.Params
+}
+
+// For returns the allocation for role, or nil if there is none.
+func (c *ParamsConfig) For(role proto.Role) *sim.Params {
+	if role == proto.RoleAttacker {
+		return c.Attacker
+	}
+	return c.Defender
+}
+
+// Set sets the allocation for role.
+func (c *ParamsConfig) Set(role proto.Role, p sim.Params) {
+	if role == proto.RoleAttacker {
+		c.Attacker = &p
+	} else {
+		c.Defender = &p
+	}
+}
+
+func (c *ParamsConfig) Save(fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func LoadParamsConfig(fn string) (*ParamsConfig, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := &ParamsConfig{}
+	if err := json.NewDecoder(f).Decode(c); err != nil {
+		return nil, fmt.Errorf("Bad params config %s: %w", fn, err)
+	}
+	return c, nil
+}
+
+// CheckParams returns an error if p is not a valid START allocation for the
+// game.
+func CheckParams(p sim.Params, info *proto.GameStaticInfo) error {
+	if p.Fuel < 0 || p.Power < 0 || p.Cooling < 0 || p.Clones < 1 {
+		return fmt.Errorf("Bad params %+v: negative values or no ships", p)
+	}
+	if max := info.Constraints.MaxPoints; max > 0 && p.Cost() > max {
+		return fmt.Errorf("Bad params %+v: cost %d exceeds %d points", p, p.Cost(), max)
+	}
+	return nil
+}
+
+// fillFuel spends the points left in the game budget on fuel.
+func fillFuel(p sim.Params, info *proto.GameStaticInfo) sim.Params {
+	if max := info.Constraints.MaxPoints; p.Cost() < max {
+		p.Fuel += max - p.Cost()
+	}
+	return p
+}
+
+// tuned overrides the START allocation of a strategy. Points left over in a
+// larger game budget are spent on fuel.
+type tuned struct {
+	Strategy
+	params func(info *proto.GameStaticInfo) (*sim.Params, error)
+}
+
+func (t *tuned) Start(info *proto.GameStaticInfo) sim.Params {
+	p, err := t.params(info)
+	if err == nil && p != nil {
+		if err = CheckParams(*p, info); err == nil {
+			return fillFuel(*p, info)
+		}
+	}
+	if err != nil {
+		log.Printf("Using default params: %s", err)
+	}
+	return t.Strategy.Start(info)
+}
+
+// WithParams makes s start with p.
+func WithParams(s Strategy, p sim.Params) Strategy {
+	return &tuned{
+		Strategy: s,
+		params:   func(*proto.GameStaticInfo) (*sim.Params, error) { return &p, nil },
+	}
+}
+
+// WithParamsFile makes s start with the allocation for its role from the
+// ParamsConfig in fn. The file is read at START, so it can be updated while
+// the bot waits for the game. The strategy's own allocation is used if the
+// file has none for the role or it does not fit the game.
+func WithParamsFile(s Strategy, fn string) Strategy {
+	return &tuned{
+		Strategy: s,
+		params: func(info *proto.GameStaticInfo) (*sim.Params, error) {
+			c, err := LoadParamsConfig(fn)
+			if err != nil {
+				return nil, err
+			}
+			return c.For(info.Role), nil
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package strategy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func TestCheckParams(t *testing.T) {
+	info := &proto.GameStaticInfo{Constraints: proto.Constraints{MaxPoints: 512}}
+	assert.NoError(t, CheckParams(sim.Params{Fuel: 498, Power: 1, Cooling: 0, Clones: 5}, info))
+	assert.Error(t, CheckParams(sim.Params{Fuel: 499, Power: 1, Cooling: 0, Clones: 5}, info))
+	assert.Error(t, CheckParams(sim.Params{Fuel: 1, Power: 1, Cooling: 1}, info))
+	assert.Error(t, CheckParams(sim.Params{Fuel: -1, Clones: 1}, info))
+}
+
+func TestWithParamsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "params")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "params.json")
+
+	attacker := &proto.GameStaticInfo{Role: proto.RoleAttacker, Constraints: proto.Constraints{MaxPoints: 512}}
+	defender := &proto.GameStaticInfo{Role: proto.RoleDefender, Constraints: proto.Constraints{MaxPoints: 512}}
+	st := WithParamsFile(Idle{}, fn)
+	idle := Idle{}.Start(attacker)
+
+	// No file yet.
+	assert.Equal(t, idle, st.Start(attacker))
+
+	c := &ParamsConfig{Strategy: "idle", MaxPoints: 256}
+	c.Set(proto.RoleAttacker, sim.Params{Fuel: 100, Power: 10, Cooling: 5, Clones: 1})
+	require.NoError(t, c.Save(fn))
+	loaded, err := LoadParamsConfig(fn)
+	require.NoError(t, err)
+	assert.Equal(t, c, loaded)
+
+	// The file is read at START and the larger budget goes to fuel.
+	assert.Equal(t, sim.Params{Fuel: 410, Power: 10, Cooling: 5, Clones: 1}, st.Start(attacker))
+	assert.Equal(t, idle, st.Start(defender))
+
+	c.Set(proto.RoleDefender, sim.Params{Fuel: 500, Power: 10, Cooling: 5, Clones: 1})
+	require.NoError(t, c.Save(fn))
+	assert.Equal(t, idle, st.Start(defender))
+}
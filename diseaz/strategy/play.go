@@ -0,0 +1,110 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	gx "github.com/tarstars/icfpc2020/diseaz/interpreter"
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+)
+
+// MaxFailures is the number of consecutive bad responses Play tolerates.
+const MaxFailures = 5
+
+// Player plays one game of a strategy through the alien server protocol.
+type Player struct {
+	Ctx       *gx.Ctx
+	PlayerKey int64
+	Strategy  Strategy
+	// Timeout, if positive, limits every request, so a stuck server or
+	// interpreter costs a retry instead of the rest of the game.
+	Timeout time.Duration
+	// OnResponse is called with every server response and its decoded form,
+	// which is nil if the response is malformed.
+	OnResponse func(name string, resp gx.Token, gr *proto.GameResponse)
+}
+
+func (p *Player) command(name string, req proto.Request) (*proto.GameResponse, error) {
+	if p.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+		defer cancel()
+		p.Ctx.Context = ctx
+		defer func() { p.Ctx.Context = nil }()
+	}
+	r, err := proto.Send(p.Ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	gr, err := proto.DecodeGameResponse(r)
+	if p.OnResponse != nil {
+		p.OnResponse(name, r, gr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return gr, nil
+}
+
+// notSent tells whether a request certainly never reached the server.
+func notSent(err error) bool {
+	var e *net.OpError
+	return errors.As(err, &e) && e.Op == "dial"
+}
+
+// Play joins the game, starts it and sends the commands of the strategy until
+// the game finishes. It returns the last response.
+func (p *Player) Play() (*proto.GameResponse, error) {
+	gr, err := p.command("JOIN", proto.Join{PlayerKey: p.PlayerKey})
+	if err != nil {
+		return nil, err
+	}
+	if gr.Stage == proto.GameFinished {
+		return gr, nil
+	}
+	if gr.StaticInfo == nil {
+		return gr, fmt.Errorf("JOIN failed: no static game info")
+	}
+
+	gr, err = p.command("START", proto.Start{
+		PlayerKey: p.PlayerKey,
+		Params:    p.Strategy.Start(gr.StaticInfo),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	failures := 0
+	var cmds []proto.Command
+	pending := false
+	for gr.Stage != proto.GameFinished {
+		if !pending {
+			cmds = p.Strategy.Commands(gr.StaticInfo, gr.State)
+		}
+		next, err := p.command("COMMANDS", proto.Commands{
+			PlayerKey: p.PlayerKey,
+			Commands:  cmds,
+		})
+		if err != nil {
+			failures++
+			if failures >= MaxFailures {
+				return gr, fmt.Errorf("Giving up after %d bad responses: %w", failures, err)
+			}
+			pending = true
+			if !notSent(err) {
+				// The server may have run the commands already. Send none
+				// to learn the current state instead of running them twice.
+				cmds = nil
+			}
+			log.Printf("Skipping bad response: %s", err)
+			continue
+		}
+		failures = 0
+		pending = false
+		gr = next
+	}
+	return gr, nil
+}
@@ -0,0 +1,80 @@
+// Package strategy contains the game strategies of galaxy-bot.
+package strategy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+// Strategy plays one side of a game.
+type Strategy interface {
+	// Start chooses the initial ship parameters.
+	Start(info *proto.GameStaticInfo) sim.Params
+	// Commands returns the commands for the next tick.
+	Commands(info *proto.GameStaticInfo, state *proto.GameState) []proto.Command
+}
+
+// Factory creates a fresh strategy for one game.
+type Factory func() Strategy
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a strategy available by name. It panics if the name is
+// already taken.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("Strategy %q registered twice", name))
+	}
+	registry[name] = f
+}
+
+// New creates the strategy registered as name.
+func New(name string) (Strategy, error) {
+	registryMu.Lock()
+	f, ok := registry[name]
+	registryMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown strategy %q, expected one of %v", name, Names())
+	}
+	return f(), nil
+}
+
+// Names returns the registered strategy names in order.
+func Names() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MyShips returns the ships of the role playing info.
+func MyShips(info *proto.GameStaticInfo, state *proto.GameState) []*proto.ShipAndCommands {
+	return ShipsOf(info.Role, state)
+}
+
+// ShipsOf returns the ships of role.
+func ShipsOf(role proto.Role, state *proto.GameState) []*proto.ShipAndCommands {
+	var ships []*proto.ShipAndCommands
+	if state == nil {
+		return nil
+	}
+	for _, s := range state.Ships {
+		if s.Ship.Role == role {
+			ships = append(ships, s)
+		}
+	}
+	return ships
+}
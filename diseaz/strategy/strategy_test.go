@@ -0,0 +1,33 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tarstars/icfpc2020/diseaz/proto"
+	"github.com/tarstars/icfpc2020/diseaz/sim"
+)
+
+func TestRegistry(t *testing.T) {
+	assert.Contains(t, Names(), "idle")
+
+	_, err := New("no-such-strategy")
+	assert.Error(t, err)
+
+	assert.Panics(t, func() { Register("idle", func() Strategy { return Idle{} }) })
+}
+
+func TestIdle(t *testing.T) {
+	st, err := New("idle")
+	require.NoError(t, err)
+
+	info := &proto.GameStaticInfo{Role: proto.RoleDefender, World: sim.DefaultWorld}
+	state := &proto.GameState{Ships: []*proto.ShipAndCommands{
+		{Ship: proto.ShipState{Role: proto.RoleAttacker, ID: 0}},
+		{Ship: proto.ShipState{Role: proto.RoleDefender, ID: 1}},
+	}}
+	assert.LessOrEqual(t, st.Start(info).Cost(), int64(512))
+	assert.Empty(t, st.Commands(info, state))
+}